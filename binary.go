@@ -0,0 +1,47 @@
+package ch347
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// BE16 returns big-endian 16-bit value from the first 2 bytes of p.
+func BE16(p []byte) uint32 {
+	return uint32(p[0])<<8 | uint32(p[1])
+}
+
+// BE24 returns big-endian 24-bit value from the first 3 bytes of p.
+func BE24(p []byte) uint32 {
+	return uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+}
+
+// BE32 returns big-endian 32-bit value from the first 4 bytes of p.
+func BE32(p []byte) uint32 {
+	return uint32(p[0])<<24 | uint32(p[1])<<16 | uint32(p[2])<<8 | uint32(p[3])
+}
+
+// LE16 returns little-endian 16-bit value from the first 2 bytes of p.
+func LE16(p []byte) uint32 {
+	return uint32(p[1])<<8 | uint32(p[0])
+}
+
+// LE24 returns little-endian 24-bit value from the first 3 bytes of p.
+func LE24(p []byte) uint32 {
+	return uint32(p[2])<<16 | uint32(p[1])<<8 | uint32(p[0])
+}
+
+// LE32 returns little-endian 32-bit value from the first 4 bytes of p.
+func LE32(p []byte) uint32 {
+	return uint32(p[3])<<24 | uint32(p[2])<<16 | uint32(p[1])<<8 | uint32(p[0])
+}
+
+// ReadStruct decodes p into fixed-size struct pointed by v with given byte order.
+//
+// Example:
+//
+//	// Decode MPU-6050 accelerometer registers.
+//	var accel struct{ X, Y, Z int16 }
+//	err := ch347.ReadStruct(r, binary.BigEndian, &accel)
+func ReadStruct(p []byte, order binary.ByteOrder, v any) error {
+	return binary.Read(bytes.NewReader(p), order, v)
+}
@@ -0,0 +1,68 @@
+package ch347
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestByteOrderHelpers(t *testing.T) {
+	p := []byte{0x01, 0x02, 0x03, 0x04}
+
+	for _, tc := range []struct {
+		name      string
+		got, want uint32
+	}{
+		{"BE16", BE16(p), 0x0102},
+		{"BE24", BE24(p), 0x010203},
+		{"BE32", BE32(p), 0x01020304},
+		{"LE16", LE16(p), 0x0201},
+		{"LE24", LE24(p), 0x030201},
+		{"LE32", LE32(p), 0x04030201},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %#x, want %#x", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestByteOrderAHT2X(t *testing.T) {
+	// Status, 20 bits humidity, 20 bits temperature, CRC.
+	r := []byte{0x1c, 0x6a, 0x3b, 0x25, 0x5e, 0x3d, 0x00}
+
+	if h := BE24(r[1:]) >> 4; h != 0x6a3b2 {
+		t.Fatalf("humidity %#x", h)
+	}
+
+	if tr := BE24(r[3:]) & 0xfffff; tr != 0x55e3d {
+		t.Fatalf("temperature %#x", tr)
+	}
+}
+
+func TestByteOrderPZEM004(t *testing.T) {
+	// Read input registers response: 230.1V, 74.565A (0x00012345) sent as low word first.
+	p := []byte{0xf8, 0x04, 0x12, 0x08, 0xfd, 0x23, 0x45, 0x00, 0x01}
+
+	if v := BE16(p[3:]); v != 2301 {
+		t.Fatalf("voltage %d", v)
+	}
+
+	if a := BE16(p[7:])<<16 | BE16(p[5:]); a != 0x12345 {
+		t.Fatalf("current %#x", a)
+	}
+
+	var regs struct {
+		V      uint16
+		AL, AH uint16
+	}
+	if err := ReadStruct(p[3:], binary.BigEndian, &regs); err != nil {
+		t.Fatal(err)
+	}
+
+	if regs.V != 2301 || uint32(regs.AH)<<16|uint32(regs.AL) != 0x12345 {
+		t.Fatalf("got %+v", regs)
+	}
+
+	if err := ReadStruct(p[3:5], binary.BigEndian, &regs); err == nil {
+		t.Fatal("short data accepted")
+	}
+}
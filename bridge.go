@@ -0,0 +1,65 @@
+package ch347
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// BridgeUART copies data between UART and connection in both directions,
+// e.g. to expose UART over TCP.
+//
+// It returns once either side fails or the connection is closed by the peer,
+// closing the connection. Error of the side which stopped first is returned,
+// nil if the peer just closed the connection.
+//
+// UART has no EOF, so Dev must implement ReadWithTimeout to stop reading
+// from UART once the connection is gone. Otherwise, UART reading goroutine
+// is left blocked until the next received byte.
+func BridgeUART(u *UART, conn net.Conn) error {
+	var stop atomic.Bool
+	errc := make(chan error, 2)
+
+	go func() {
+		_, err := io.Copy(conn, &uartIdleReader{u: u, stop: &stop})
+		errc <- err
+	}()
+
+	go func() {
+		_, err := io.Copy(u, conn)
+		errc <- err
+	}()
+
+	err := <-errc
+
+	// The other goroutine exits on its own, errc is buffered for it.
+	stop.Store(true)
+	conn.Close()
+
+	return err
+}
+
+// uartIdleReader reads from UART, polling stop flag while UART is idle.
+type uartIdleReader struct {
+	u    *UART
+	stop *atomic.Bool
+}
+
+func (r *uartIdleReader) Read(p []byte) (int, error) {
+	for {
+		if r.stop.Load() {
+			return 0, io.EOF
+		}
+
+		r.u.readMu.Lock()
+		n, err := r.u.read(p, 100*time.Millisecond)
+		r.u.readMu.Unlock()
+
+		if err == ErrTimeout || (err == nil && n == 0) {
+			continue
+		}
+
+		return n, err
+	}
+}
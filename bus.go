@@ -0,0 +1,162 @@
+package ch347
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// I2CBus is implemented by types performing I2C transfers, like IO.
+//
+// Write your device drivers against I2CBus instead of *IO to keep them
+// transport agnostic.
+type I2CBus interface {
+	I2C(addr uint16, w, r []byte) error
+}
+
+// SPIBus is implemented by types performing SPI transfers, like IO.
+type SPIBus interface {
+	SPI(w, r []byte) error
+	SetCS(enable bool) error
+}
+
+// GPIOBus is implemented by types controlling GPIO pins, like IO.
+type GPIOBus interface {
+	WritePin(pin Pin, output bool, level bool) error
+	ReadPin(pin Pin) (bool, error)
+}
+
+var (
+	_ I2CBus  = (*IO)(nil)
+	_ SPIBus  = (*IO)(nil)
+	_ GPIOBus = (*IO)(nil)
+
+	_ io.ReadWriteCloser = (*UART)(nil)
+	_ io.ReadWriteCloser = (*UARTStream)(nil)
+	_ io.ReadWriteCloser = (*UARTPipe)(nil)
+	_ io.ReadWriter      = (*ReconnectingUART)(nil)
+
+	_ HIDDev = (*SerializingHIDDev)(nil)
+)
+
+// ReadInto reads registers starting from reg of device on given address
+// and decodes them into fixed-size struct pointed by v with given byte order.
+//
+// Example:
+//
+//	// Read MPU-6050 accelerometer and gyroscope registers at once.
+//	var m struct {
+//		AX, AY, AZ int16
+//		Temp       int16
+//		GX, GY, GZ int16
+//	}
+//	err := ch347.ReadInto(c, 0x68, []byte{0x3b}, binary.BigEndian, &m)
+func ReadInto(bus I2CBus, addr uint16, reg []byte, order binary.ByteOrder, v any) error {
+	size := binary.Size(v)
+	if size < 0 {
+		return errors.New("v must be a pointer to fixed-size value")
+	}
+
+	r := make([]byte, size)
+	err := bus.I2C(addr, reg, r)
+	if err != nil {
+		return err
+	}
+
+	return ReadStruct(r, order, v)
+}
+
+// ReadFloat32 reads 4 bytes starting from reg of device on given address
+// and decodes them as IEEE-754 float with given byte order.
+//
+// Example:
+//
+//	// Read temperature float register.
+//	t, err := ch347.ReadFloat32(c, 0x44, []byte{0x00}, binary.LittleEndian)
+func ReadFloat32(bus I2CBus, addr uint16, reg []byte, order binary.ByteOrder) (float32, error) {
+	r := make([]byte, 4)
+
+	err := bus.I2C(addr, reg, r)
+	if err != nil {
+		return 0, err
+	}
+
+	return math.Float32frombits(order.Uint32(r)), nil
+}
+
+// WriteRegWord writes val as width (1-4) bytes with given byte order after register reg
+// of device on given address.
+//
+// Example:
+//
+//	// Set 24-bit DAC code MSB first.
+//	err := ch347.WriteRegWord(c, 0x4c, 0x30, 0x7fffff, 3, binary.BigEndian)
+func WriteRegWord(bus I2CBus, addr uint16, reg uint8, val uint32, width int, order binary.ByteOrder) error {
+	if width < 1 || width > 4 {
+		return fmt.Errorf("invalid word width %d", width)
+	}
+
+	w := make([]byte, 1+width)
+	w[0] = reg
+
+	// Detect order, binary.ByteOrder has no 24-bit methods.
+	var probe [2]byte
+	order.PutUint16(probe[:], 0x0102)
+	bigEndian := probe[0] == 0x01
+
+	for i := 0; i < width; i++ {
+		shift := 8 * i
+		if bigEndian {
+			shift = 8 * (width - 1 - i)
+		}
+
+		w[1+i] = byte(val >> shift)
+	}
+
+	return bus.I2C(addr, w, nil)
+}
+
+// ReadSPIInto writes w, then reads and decodes data into fixed-size struct pointed by v
+// with given byte order. CS is asserted for the whole transfer, with SetCS,
+// or by SPI itself if bus is IO with AutoCS set.
+//
+// Example:
+//
+//	// Read BME280 calibration registers, MSB of the address set for reading.
+//	var cal struct {
+//		T1 uint16
+//		T2 int16
+//		T3 int16
+//	}
+//	err := ch347.ReadSPIInto(c, []byte{0x88 | 0x80}, binary.LittleEndian, &cal)
+func ReadSPIInto(bus SPIBus, w []byte, order binary.ByteOrder, v any) error {
+	size := binary.Size(v)
+	if size < 0 {
+		return errors.New("v must be a pointer to fixed-size value")
+	}
+
+	r := make([]byte, size)
+
+	var err error
+	if c, ok := bus.(*IO); ok && c.AutoCS != AutoCSOff {
+		err = bus.SPI(w, r)
+	} else {
+		err = bus.SetCS(true)
+		if err != nil {
+			return err
+		}
+
+		err = bus.SPI(w, r)
+		if cerr := bus.SetCS(false); err == nil {
+			err = cerr
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return ReadStruct(r, order, v)
+}
@@ -0,0 +1,199 @@
+package ch347
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// spiLog is SPIBus logging calls, with failing SPI or SetCS(false) if set.
+type spiLog struct {
+	ops        []string
+	spiErr     error
+	releaseErr error
+}
+
+func (b *spiLog) SPI(w, r []byte) error {
+	b.ops = append(b.ops, "spi")
+	for i := range r {
+		r[i] = byte(i + 1)
+	}
+
+	return b.spiErr
+}
+
+func (b *spiLog) SetCS(enable bool) error {
+	if !enable {
+		b.ops = append(b.ops, "0-")
+		return b.releaseErr
+	}
+
+	b.ops = append(b.ops, "0+")
+	return nil
+}
+
+func TestReadSPIInto(t *testing.T) {
+	var v struct{ A, B uint16 }
+
+	bus := &spiLog{}
+	if err := ReadSPIInto(bus, []byte{0x88}, binary.BigEndian, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.A != 0x0102 || v.B != 0x0304 {
+		t.Fatalf("got %+v", v)
+	}
+
+	if want := []string{"0+", "spi", "0-"}; !reflect.DeepEqual(bus.ops, want) {
+		t.Fatalf("got %v, want %v", bus.ops, want)
+	}
+
+	// CS is released even if the transfer fails.
+	fail := errors.New("fail")
+
+	bus = &spiLog{spiErr: fail}
+	if err := ReadSPIInto(bus, []byte{0x88}, binary.BigEndian, &v); err != fail {
+		t.Fatalf("got %v", err)
+	}
+
+	if want := []string{"0+", "spi", "0-"}; !reflect.DeepEqual(bus.ops, want) {
+		t.Fatalf("got %v, want %v", bus.ops, want)
+	}
+
+	bus = &spiLog{releaseErr: fail}
+	if err := ReadSPIInto(bus, []byte{0x88}, binary.BigEndian, &v); err != fail {
+		t.Fatalf("SetCS(false) error: got %v", err)
+	}
+}
+
+func TestReadSPIIntoAutoCS(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d, AutoCS: AutoCS1}
+
+	if err := c.SetSPI(SPIMode0, SPIClock0, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	d.writes = nil
+
+	var v struct{ A, B uint8 }
+	if err := ReadSPIInto(c, []byte{0x88}, binary.BigEndian, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	// CS is driven once, by SPI.
+	if want := []string{"1+", "spi", "spi", "1-"}; !reflect.DeepEqual(csOps(d.written()), want) {
+		t.Fatalf("got %v, want %v", csOps(d.written()), want)
+	}
+
+	if v.A != 0 || v.B != 1 {
+		t.Fatalf("got %+v", v)
+	}
+}
+
+func TestReadInto(t *testing.T) {
+	d := &i2cSim{t: t}
+	c := &IO{Dev: d}
+
+	var v struct {
+		A uint8
+		B uint16
+		C uint32
+		D int8
+	}
+	if err := ReadInto(c, 0x68, []byte{0x3b}, binary.BigEndian, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulated device reads 0x00, 0x01, ... 0x07.
+	if v.A != 0x00 || v.B != 0x0102 || v.C != 0x03040506 || v.D != 0x07 {
+		t.Fatalf("got %+v", v)
+	}
+
+	if d.reads != 8 || len(d.writes) == 0 || !reflect.DeepEqual(d.writes[0], []byte{0x68 << 1, 0x3b}) {
+		t.Fatalf("%d bytes read, writes % x", d.reads, d.writes)
+	}
+
+	var le struct{ B uint16 }
+	if err := ReadInto(c, 0x68, []byte{0x3b}, binary.LittleEndian, &le); err != nil {
+		t.Fatal(err)
+	}
+
+	if le.B != 0x0908 {
+		t.Fatalf("little endian: got %#x", le.B)
+	}
+
+	var m map[int]int
+	if err := ReadInto(c, 0x68, nil, binary.BigEndian, &m); err == nil {
+		t.Fatal("map accepted")
+	}
+}
+
+// regBus is I2CBus reading data and logging writes.
+type regBus struct {
+	data   []byte
+	writes [][]byte
+}
+
+func (b *regBus) I2C(addr uint16, w, r []byte) error {
+	b.writes = append(b.writes, append([]byte(nil), w...))
+	copy(r, b.data)
+
+	return nil
+}
+
+func TestReadFloat32(t *testing.T) {
+	for _, tc := range []struct {
+		data  []byte
+		order binary.ByteOrder
+	}{
+		{[]byte{0x41, 0xcc, 0x00, 0x00}, binary.BigEndian}, // 25.5.
+		{[]byte{0x00, 0x00, 0xcc, 0x41}, binary.LittleEndian},
+	} {
+		bus := &regBus{data: tc.data}
+
+		v, err := ReadFloat32(bus, 0x44, []byte{0x00}, tc.order)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v != 25.5 {
+			t.Fatalf("% x %v: got %v", tc.data, tc.order, v)
+		}
+
+		if !reflect.DeepEqual(bus.writes, [][]byte{{0x00}}) {
+			t.Fatalf("got writes % x", bus.writes)
+		}
+	}
+}
+
+func TestWriteRegWord(t *testing.T) {
+	for _, tc := range []struct {
+		val   uint32
+		width int
+		order binary.ByteOrder
+		want  []byte
+	}{
+		{0x1234, 2, binary.BigEndian, []byte{0x30, 0x12, 0x34}},
+		{0x1234, 2, binary.LittleEndian, []byte{0x30, 0x34, 0x12}},
+		{0x7fabcd, 3, binary.BigEndian, []byte{0x30, 0x7f, 0xab, 0xcd}},
+		{0x7fabcd, 3, binary.LittleEndian, []byte{0x30, 0xcd, 0xab, 0x7f}},
+		{0xff7fabcd, 3, binary.BigEndian, []byte{0x30, 0x7f, 0xab, 0xcd}}, // Upper byte dropped.
+	} {
+		bus := &regBus{}
+		if err := WriteRegWord(bus, 0x4c, 0x30, tc.val, tc.width, tc.order); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(bus.writes, [][]byte{tc.want}) {
+			t.Fatalf("%#x, %d bytes, %v: got % x, want % x", tc.val, tc.width, tc.order, bus.writes, tc.want)
+		}
+	}
+
+	for _, width := range []int{0, 5} {
+		if err := WriteRegWord(&regBus{}, 0x4c, 0x30, 0, width, binary.BigEndian); err == nil {
+			t.Fatalf("width %d accepted", width)
+		}
+	}
+}
@@ -7,8 +7,10 @@
 package ch347
 
 import (
+	"errors"
 	"io"
 	"sync"
+	"time"
 )
 
 // IO implements methods to access CH347 SPI+I2C+GPIO.
@@ -17,13 +19,137 @@ import (
 type IO struct {
 	mu  sync.Mutex
 	Dev HIDDev
+
+	// GPIOStrictness controls how WritePin validates device response.
+	// Defaults to GPIOStrict.
+	GPIOStrictness GPIOStrictness
+
+	// GPIOCoalesce makes concurrent WritePin calls within this window share a single
+	// request to the device. Every WritePin waits for the window to pass, so it adds up
+	// to GPIOCoalesce latency. A pin changed again within the window starts the next request,
+	// so no level is lost and changes are applied in order. Zero disables coalescing.
+	GPIOCoalesce time.Duration
+	gpioBatcher  gpioBatcher
+
+	// ReadTimeout limits waiting for device responses, so a lost response
+	// results in ErrTimeout instead of blocking forever.
+	// Dev must implement ReadWithTimeout. Zero means no timeout.
+	ReadTimeout time.Duration
+
+	// I2CAckLastRead makes I2C ACK the last byte read instead of NACKing it,
+	// for non-compliant devices misbehaving on NACK.
+	//
+	// Note: NACK is what I2C spec requires, and the chip might fail next operation
+	// without it. Use it only if your device needs it.
+	I2CAckLastRead bool
+
+	// I2CNoStop makes I2C leave the bus held without STOP after a transfer, so a multi-step
+	// protocol can continue in the next I2C call, which starts with a repeated START.
+	//
+	// Note: other devices can't use the bus until STOP, call I2CStop to release it eventually.
+	I2CNoStop bool
+
+	// I2CPageSize is EEPROM page size used by I2CWriteFrom, so writes never cross page boundaries.
+	// Zero means 256 bytes chunks with no alignment.
+	I2CPageSize int
+
+	// DriveCSIdle makes SetSPI drive both CS lines inactive right after configuration,
+	// so they don't glitch before the first transfer. Useful for multi-drop buses
+	// and devices with CS gated power.
+	DriveCSIdle bool
+
+	// AutoCS makes SPI assert selected CS line for the duration of every call,
+	// so no SetCS calls are needed around single transfers.
+	//
+	// It's software sequenced: SPI configuration packet has no known auto CS bits,
+	// so SPI sends the same CS packets SetCS does, within the same lock as the transfer.
+	// That keeps other goroutines from squeezing in between CS and the transfer,
+	// but every transfer still costs two extra USB writes, like with SetCS. Defaults to AutoCSOff.
+	AutoCS AutoCS
+
+	// SPIStrictLength makes SPIDuplex return ErrSPILength when both w and r are given
+	// and their lengths differ, instead of padding w or discarding MISO bytes past r.
+	SPIStrictLength bool
+
+	// ActivityLED enables ACT led (GPIO4) toggling on every SPI and I2C operation.
+	//
+	// Note: every toggle costs an extra USB round trip.
+	ActivityLED bool
+	activityOps uint32
+
+	// Resync controls recovery from ErrInvalidResponse of SPI and GPIO operations.
+	// Defaults to ResyncNone.
+	Resync ResyncPolicy
+
+	// MinOpInterval is minimum gap between packets sent to the device.
+	// Spacing them reduces USB contention on busy hubs. Zero means no throttling.
+	MinOpInterval time.Duration
+	throttle      throttle
+
+	// Current configuration.
+	spiSet       bool
+	spiStale     bool // Reset by SetI2C, re-applied before the next SPI transfer.
+	spiMode      SPIMode
+	spiClock     SPIClock
+	spiByteOrder SPIByteOrder
+	csPolarity   byte // Config byte 25.
+	i2cSet       bool
+	i2cMode      I2CMode
+	packetLen    int // Report size found by DetectReportSize, zero means maxPacketLen.
+
+	metrics ioMetrics
+
+	pinRoles [8]PinRole
+
+	poller pinPoller // Shared by WatchPin and WatchPinContext.
 }
 
 // UART implements ReadWriter interface to access CH347 UART.
 //
 // Pass first hidraw device.
 type UART struct {
+	readMu  sync.Mutex
+	writeMu sync.Mutex
+
 	Dev HIDDev
+
+	// ActivityIO, if set, toggles its ACT led on every UART read and write.
+	// IO.ActivityLED must be enabled too.
+	ActivityIO *IO
+
+	// DiscardOnSet discards any received data after Set has applied new settings.
+	// Dev must implement ReadWithTimeout.
+	DiscardOnSet bool
+
+	// ByteTimeout is idle time after the last received byte before the chip sends
+	// partially filled report to the host, applied by Set. Lower values make reads more
+	// responsive at the cost of more USB traffic, higher values reduce traffic.
+	// Resolution is 100us, up to 25.5ms. Zero keeps chip default.
+	//
+	// Note: there is no known FIFO trigger level setting, this is the closest knob.
+	ByteTimeout time.Duration
+
+	// MinOpInterval is minimum gap between consecutive reads and writes.
+	// Zero means no throttling.
+	MinOpInterval time.Duration
+	throttle      throttle
+
+	// CoalesceDelay makes Write buffer small writes and send them together once
+	// the delay since the first buffered write has passed, or 510 bytes are buffered.
+	// This saves a USB transaction per write for byte-by-byte writers, at the cost
+	// of up to CoalesceDelay latency. Call Flush to send buffered data right away.
+	//
+	// Write errors are returned by the next Write or Flush. Zero disables coalescing.
+	CoalesceDelay time.Duration
+	wbuf          []byte
+	flushTimer    *time.Timer
+	coalesceErr   error
+
+	cfgMu          sync.Mutex
+	lineCoding     []byte // Last config report sent by Set.
+	interCharDelay time.Duration
+
+	metrics uartMetrics
 }
 
 // # Note:
@@ -57,5 +183,112 @@ type HIDDev interface {
 	SendFeatureReport(p []byte) (int, error)
 }
 
+// timeoutReader is implemented by HID devices supporting reads with timeout,
+// like [github.com/sstallion/go-hid] Device.
+type timeoutReader interface {
+	ReadWithTimeout(p []byte, timeout time.Duration) (int, error)
+}
+
+// readWithTimeout reads a report, returning ErrTimeout if nothing was read in time.
+func readWithTimeout(d timeoutReader, p []byte, timeout time.Duration) (int, error) {
+	n, err := d.ReadWithTimeout(p, timeout)
+	if n == 0 || isTimeout(err) { // hidapi returns 0 on timeout and -1 on error.
+		return 0, ErrTimeout
+	}
+
+	return n, err
+}
+
+// isTimeout reports whether err is a read timeout: ErrTimeout, an error with Timeout() method
+// returning true, or [github.com/sstallion/go-hid] ErrTimeout, which has no type to check against.
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrTimeout) {
+		return true
+	}
+
+	var t interface{ Timeout() bool }
+	if errors.As(err, &t) && t.Timeout() {
+		return true
+	}
+
+	return err.Error() == "timeout"
+}
+
 // CH347 receives and sends 512 bytes long packets.
 const maxPacketLen = 512
+
+// minPacketLen is the smallest report size DetectReportSize sets, full speed HID report size.
+const minPacketLen = 64
+
+// packetSize returns size of packets sent to the device.
+func (c *IO) packetSize() int {
+	if c.packetLen == 0 {
+		return maxPacketLen
+	}
+
+	return c.packetLen
+}
+
+// read reads a response from the device with ReadTimeout applied.
+func (c *IO) read(p []byte) (int, error) {
+	if d, ok := c.Dev.(timeoutReader); ok && c.ReadTimeout > 0 {
+		return readWithTimeout(d, p, c.ReadTimeout)
+	}
+
+	n, err := c.Dev.Read(p)
+	if isTimeout(err) {
+		return 0, ErrTimeout
+	}
+
+	return n, err
+}
+
+// drain reads and discards pending responses.
+func (c *IO) drain() error {
+	d, ok := c.Dev.(timeoutReader)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	return drain(d)
+}
+
+// drainTimeout caps drain, so it returns even if the device keeps sending, like a busy UART.
+const drainTimeout = 100 * time.Millisecond
+
+// drain reads and discards reports until there is nothing left to read, or drainTimeout passes.
+func drain(d timeoutReader) error {
+	p := make([]byte, maxPacketLen)
+	for end := time.Now().Add(drainTimeout); time.Now().Before(end); {
+		_, err := readWithTimeout(d, p, 10*time.Millisecond)
+		if err == ErrTimeout {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write sends a packet to the device, treating a short write as an error.
+func (c *IO) write(p []byte) error {
+	c.throttle.wait(c.MinOpInterval)
+
+	n, err := c.Dev.Write(p)
+	if err != nil {
+		return err
+	}
+
+	if n < len(p) {
+		return io.ErrShortWrite
+	}
+
+	return nil
+}
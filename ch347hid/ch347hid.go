@@ -0,0 +1,218 @@
+// Package ch347hid locates and opens CH347 hidraw interfaces with [github.com/sstallion/go-hid].
+//
+// It's a separate module, so the ch347 package itself stays free of the HIDAPI dependency.
+// ch347.UART and ch347.IO still accept any ch347.HIDDev, e.g. a mock for testing,
+// and Finder can enumerate and open mocks instead of real devices.
+//
+// Don't forget to allow access to hidraw devices:
+//
+//	sudo chmod 777 /dev/hidraw{5,6}
+package ch347hid
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/serfreeman1337/go-ch347"
+	"github.com/sstallion/go-hid"
+)
+
+// ErrNotFound is returned if no CH347 interface was found.
+// A chip found in other mode than 2 is reported with ch347.CheckMode error wrapped in it.
+var ErrNotFound = errors.New("no CH347 found")
+
+// USB interface numbers of CH347 in mode 2.
+const (
+	InterfaceUART = 0
+	InterfaceIO   = 1 // SPI+I2C+GPIO.
+)
+
+// DeviceInfo describes a CH347 interface.
+type DeviceInfo struct {
+	Path      string // hidraw path, e.g. "/dev/hidraw6". Empty in other modes than 2.
+	Serial    string // Chip serial number, the same for both interfaces.
+	Interface int    // InterfaceUART or InterfaceIO in mode 2, -1 in other modes.
+	Mode      ch347.Mode
+	Release   uint16 // USB device release number (bcdDevice), the chip version.
+}
+
+// Finder locates and opens CH347 interfaces.
+// The zero value uses go-hid and Linux sysfs, set its fields to find and open mocks in tests.
+//
+// Example:
+//
+//	f := ch347hid.Finder{
+//		EnumerateHID: func(vid, pid uint16, fn hid.EnumFunc) error {
+//			return fn(&hid.DeviceInfo{Path: "mock", ProductID: pid, InterfaceNbr: ch347hid.InterfaceIO})
+//		},
+//		OpenPath: func(path string) (ch347.HIDDev, error) {
+//			return mockDev, nil
+//		},
+//	}
+//	c, err := f.OpenIO()
+type Finder struct {
+	// EnumerateHID lists HID devices, hid.Enumerate if nil.
+	EnumerateHID func(vid, pid uint16, fn hid.EnumFunc) error
+
+	// OpenPath opens HID device by path, hid.OpenPath if nil.
+	OpenPath func(path string) (ch347.HIDDev, error)
+
+	// SysfsUSB is the directory searched for chips in other modes than 2, "/sys/bus/usb/devices" if empty.
+	SysfsUSB string
+}
+
+// Enumerate returns HID interfaces of all CH347 chips in mode 2.
+//
+// Chips in other modes have no HID interface. On Linux they are found in sysfs
+// and returned with their Mode and no Path, so they can't be opened.
+func Enumerate() ([]DeviceInfo, error) {
+	return Finder{}.Enumerate()
+}
+
+// OpenUART opens UART interface of the first CH347 found.
+func OpenUART() (*ch347.UART, error) {
+	return Finder{}.OpenUART()
+}
+
+// OpenIO opens SPI+I2C+GPIO interface of the first CH347 found.
+func OpenIO() (*ch347.IO, error) {
+	return Finder{}.OpenIO()
+}
+
+// Open opens both interfaces of CH347 with given serial number, or of the first one found
+// if serial is empty.
+//
+// Example:
+//
+//	d, err := ch347hid.Open("")
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer d.Close()
+func Open(serial string) (*ch347.Device, error) {
+	return Finder{}.Open(serial)
+}
+
+// Enumerate is like package Enumerate.
+func (f Finder) Enumerate() ([]DeviceInfo, error) {
+	enumerate := f.EnumerateHID
+	if enumerate == nil {
+		enumerate = hid.Enumerate
+	}
+
+	dir := f.SysfsUSB
+	if dir == "" {
+		dir = sysfsUSB
+	}
+
+	var infos []DeviceInfo
+
+	err := enumerate(ch347.VendorID, ch347.Mode2.ProductID(), func(info *hid.DeviceInfo) error {
+		if info.InterfaceNbr != InterfaceUART && info.InterfaceNbr != InterfaceIO {
+			return nil
+		}
+
+		infos = append(infos, DeviceInfo{
+			Path:      info.Path,
+			Serial:    info.SerialNbr,
+			Interface: info.InterfaceNbr,
+			Mode:      ch347.Mode2,
+			Release:   info.ReleaseNbr,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(infos, usbChips(dir)...), nil
+}
+
+// OpenUART is like package OpenUART.
+func (f Finder) OpenUART() (*ch347.UART, error) {
+	dev, err := f.open(InterfaceUART, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ch347.UART{Dev: dev}, nil
+}
+
+// OpenIO is like package OpenIO.
+func (f Finder) OpenIO() (*ch347.IO, error) {
+	dev, err := f.open(InterfaceIO, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ch347.IO{Dev: dev}, nil
+}
+
+// Open is like package Open.
+func (f Finder) Open(serial string) (*ch347.Device, error) {
+	uartDev, err := f.open(InterfaceUART, serial)
+	if err != nil {
+		return nil, err
+	}
+
+	ioDev, err := f.open(InterfaceIO, serial)
+	if err != nil {
+		if c, ok := uartDev.(io.Closer); ok {
+			c.Close()
+		}
+
+		return nil, err
+	}
+
+	return ch347.Open(uartDev, ioDev), nil
+}
+
+// open opens given interface of the chip with given serial number, any chip if it's empty.
+func (f Finder) open(iface int, serial string) (ch347.HIDDev, error) {
+	infos, err := f.Enumerate()
+	if err != nil {
+		return nil, err
+	}
+
+	// Chip found, but in unsupported mode.
+	var modeErr error
+
+	for _, info := range infos {
+		if serial != "" && info.Serial != serial {
+			continue
+		}
+
+		if info.Mode != ch347.Mode2 {
+			if modeErr == nil {
+				modeErr = ch347.CheckMode(ch347.VendorID, info.Mode.ProductID())
+			}
+
+			continue
+		}
+
+		if info.Interface == iface {
+			return f.openPath(info.Path)
+		}
+	}
+
+	if modeErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotFound, modeErr)
+	}
+
+	return nil, ErrNotFound
+}
+
+func (f Finder) openPath(path string) (ch347.HIDDev, error) {
+	if f.OpenPath != nil {
+		return f.OpenPath(path)
+	}
+
+	dev, err := hid.OpenPath(path)
+	if err != nil {
+		return nil, err // Not a typed nil in HIDDev.
+	}
+
+	return dev, nil
+}
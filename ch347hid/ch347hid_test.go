@@ -0,0 +1,140 @@
+package ch347hid
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/serfreeman1337/go-ch347"
+	"github.com/sstallion/go-hid"
+)
+
+// fakeDev is a HID device remembering its path.
+type fakeDev struct {
+	path   string
+	closed bool
+}
+
+func (d *fakeDev) Read(p []byte) (int, error)              { return 0, nil }
+func (d *fakeDev) Write(p []byte) (int, error)             { return len(p), nil }
+func (d *fakeDev) SendFeatureReport(p []byte) (int, error) { return len(p), nil }
+func (d *fakeDev) Close() error                            { d.closed = true; return nil }
+
+// fakeFinder returns Finder enumerating given HID devices, with sysfs in dir.
+func fakeFinder(dir string, devs ...hid.DeviceInfo) (Finder, *[]*fakeDev) {
+	var opened []*fakeDev
+
+	return Finder{
+		EnumerateHID: func(vid, pid uint16, fn hid.EnumFunc) error {
+			for i := range devs {
+				if devs[i].VendorID != vid || devs[i].ProductID != pid {
+					continue
+				}
+
+				if err := fn(&devs[i]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		OpenPath: func(path string) (ch347.HIDDev, error) {
+			d := &fakeDev{path: path}
+			opened = append(opened, d)
+
+			return d, nil
+		},
+		SysfsUSB: dir,
+	}, &opened
+}
+
+func TestFinderOpen(t *testing.T) {
+	f, opened := fakeFinder(t.TempDir(),
+		hid.DeviceInfo{Path: "/dev/hidraw5", VendorID: 0x1a86, ProductID: 0x55dc, SerialNbr: "A", InterfaceNbr: 0},
+		hid.DeviceInfo{Path: "/dev/hidraw6", VendorID: 0x1a86, ProductID: 0x55dc, SerialNbr: "A", InterfaceNbr: 1},
+		hid.DeviceInfo{Path: "/dev/hidraw7", VendorID: 0x1a86, ProductID: 0x55dc, SerialNbr: "B", InterfaceNbr: 0},
+		hid.DeviceInfo{Path: "/dev/hidraw8", VendorID: 0x1a86, ProductID: 0x55dc, SerialNbr: "B", InterfaceNbr: 1},
+		hid.DeviceInfo{Path: "/dev/hidraw9", VendorID: 0x1a86, ProductID: 0x55dc, SerialNbr: "B", InterfaceNbr: 2},
+		hid.DeviceInfo{Path: "/dev/hidraw1", VendorID: 0x046d, ProductID: 0x55dc, InterfaceNbr: 1},
+	)
+
+	infos, err := f.Enumerate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(infos) != 4 || infos[3].Path != "/dev/hidraw8" || infos[3].Mode != ch347.Mode2 {
+		t.Fatalf("got %+v", infos)
+	}
+
+	c, err := f.OpenIO()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Dev.(*fakeDev).path != "/dev/hidraw6" {
+		t.Fatalf("opened %s", c.Dev.(*fakeDev).path)
+	}
+
+	d, err := f.Open("B")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.UART.Dev.(*fakeDev).path != "/dev/hidraw7" || d.IO.Dev.(*fakeDev).path != "/dev/hidraw8" {
+		t.Fatalf("opened %+v", *opened)
+	}
+
+	if _, err := f.Open("C"); err != ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestFinderWrongMode(t *testing.T) {
+	dir := t.TempDir()
+	usbDevice(t, dir, "1-1", map[string]string{"idVendor": "1a86", "idProduct": "55db", "serial": "A"})
+
+	f, opened := fakeFinder(dir)
+
+	_, err := f.OpenUART()
+	if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "mode 1") {
+		t.Fatalf("got %v, want mode error", err)
+	}
+
+	if len(*opened) != 0 {
+		t.Fatal("chip in mode 1 opened")
+	}
+
+	// Mode error is only for matching serial.
+	if _, err := f.Open("B"); err != ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestFinderOpenCloses(t *testing.T) {
+	// IO interface is missing, already opened UART must be closed.
+	f, opened := fakeFinder(t.TempDir(),
+		hid.DeviceInfo{Path: "/dev/hidraw5", VendorID: 0x1a86, ProductID: 0x55dc, InterfaceNbr: 0},
+	)
+
+	if _, err := f.Open(""); err != ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+
+	if len(*opened) != 1 || !(*opened)[0].closed {
+		t.Fatal("UART not closed")
+	}
+}
+
+func TestFinderEnumerateError(t *testing.T) {
+	want := errors.New("enumerate failed")
+
+	f := Finder{
+		EnumerateHID: func(vid, pid uint16, fn hid.EnumFunc) error { return want },
+		SysfsUSB:     t.TempDir(),
+	}
+
+	if _, err := f.OpenIO(); err != want {
+		t.Fatalf("got %v", err)
+	}
+}
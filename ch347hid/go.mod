@@ -0,0 +1,12 @@
+module github.com/serfreeman1337/go-ch347/ch347hid
+
+go 1.21.5
+
+replace github.com/serfreeman1337/go-ch347 => ../
+
+require (
+	github.com/serfreeman1337/go-ch347 v0.0.0-unpublished
+	github.com/sstallion/go-hid v0.14.1
+)
+
+require golang.org/x/sys v0.8.0 // indirect
@@ -0,0 +1,69 @@
+package ch347hid
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/serfreeman1337/go-ch347"
+)
+
+// USB devices directory of Linux sysfs.
+const sysfsUSB = "/sys/bus/usb/devices"
+
+// usbChips returns CH347 chips in modes other than 2 found in USB devices directory dir.
+//
+// Such chips expose no HID interface, so HIDAPI can't see them.
+// Nothing is found where sysfs isn't available.
+func usbChips(dir string) []DeviceInfo {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var infos []DeviceInfo
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+
+		// Interface entries like "1-1:1.0" have no IDs and are skipped here.
+		vid, err := readHex(path, "idVendor")
+		if err != nil || vid != ch347.VendorID {
+			continue
+		}
+
+		pid, err := readHex(path, "idProduct")
+		if err != nil {
+			continue
+		}
+
+		m, ok := ch347.ModeFromPID(pid)
+		if !ok || m == ch347.Mode2 {
+			continue
+		}
+
+		release, _ := readHex(path, "bcdDevice")
+		serial, _ := os.ReadFile(filepath.Join(path, "serial"))
+
+		infos = append(infos, DeviceInfo{
+			Serial:    strings.TrimSpace(string(serial)),
+			Interface: -1,
+			Mode:      m,
+			Release:   release,
+		})
+	}
+
+	return infos
+}
+
+// readHex reads sysfs attribute with 16-bit hex value.
+func readHex(dir, name string) (uint16, error) {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseUint(strings.TrimSpace(string(b)), 16, 16)
+	return uint16(v), err
+}
@@ -0,0 +1,54 @@
+package ch347hid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/serfreeman1337/go-ch347"
+)
+
+// usbDevice creates sysfs USB device entry with given attributes.
+func usbDevice(t *testing.T, dir, name string, attrs map[string]string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range attrs {
+		if err := os.WriteFile(filepath.Join(path, k), []byte(v+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUSBChips(t *testing.T) {
+	dir := t.TempDir()
+
+	usbDevice(t, dir, "1-1", map[string]string{"idVendor": "1a86", "idProduct": "55db", "bcdDevice": "0241", "serial": "A1"})
+	usbDevice(t, dir, "1-2", map[string]string{"idVendor": "1a86", "idProduct": "55dc"}) // Mode 2, found by HIDAPI.
+	usbDevice(t, dir, "1-3", map[string]string{"idVendor": "1a86", "idProduct": "7523"}) // CH340.
+	usbDevice(t, dir, "1-4", map[string]string{"idVendor": "046d", "idProduct": "55da"})
+	usbDevice(t, dir, "1-1:1.0", map[string]string{"bInterfaceNumber": "00"})
+	usbDevice(t, dir, "2-1", map[string]string{"idVendor": "1a86", "idProduct": "55dd"})
+
+	infos := usbChips(dir)
+	if len(infos) != 2 {
+		t.Fatalf("got %+v", infos)
+	}
+
+	want := DeviceInfo{Serial: "A1", Interface: -1, Mode: ch347.Mode1, Release: 0x0241}
+	if infos[0] != want {
+		t.Fatalf("got %+v, want %+v", infos[0], want)
+	}
+
+	if infos[1].Mode != ch347.Mode3 || infos[1].Path != "" {
+		t.Fatalf("got %+v", infos[1])
+	}
+
+	if infos := usbChips(filepath.Join(dir, "missing")); infos != nil {
+		t.Fatalf("got %+v without sysfs", infos)
+	}
+}
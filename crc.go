@@ -0,0 +1,47 @@
+package ch347
+
+// CRC8 returns CRC-8 with 0x31 polynomial and 0xff initial value,
+// used by AHT2X and Sensirion sensors.
+func CRC8(p []byte) uint8 {
+	crc := uint8(0xff)
+
+	for _, a := range p {
+		crc ^= a
+
+		for i := 8; i > 0; i-- {
+			if crc&0x80 != 0x00 {
+				crc = (crc << 1) ^ 0x31
+			} else {
+				crc = (crc << 1)
+			}
+		}
+	}
+
+	return crc
+}
+
+// CRC8Trailer returns CRC8 of p as a single byte slice, ready to be passed as crcFn
+// to I2CCRC and UART.ReadCRCFrame.
+func CRC8Trailer(p []byte) []byte {
+	return []byte{CRC8(p)}
+}
+
+// CRC16Modbus returns Modbus CRC-16. It's sent over the wire as low byte first.
+func CRC16Modbus(p []byte) uint16 {
+	crc := uint16(0xffff)
+
+	for _, a := range p {
+		crc ^= uint16(a)
+
+		for i := 8; i != 0; i-- {
+			if (crc & 0x0001) != 0 {
+				crc >>= 1
+				crc ^= 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+
+	return crc
+}
@@ -0,0 +1,102 @@
+package ch347
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Device represents the whole chip: its UART and SPI+I2C+GPIO interfaces.
+//
+// Example:
+//
+//	uartDev, _ := hid.OpenPath("/dev/hidraw5")
+//	ioDev, _ := hid.OpenPath("/dev/hidraw6")
+//
+//	d := ch347.Open(uartDev, ioDev)
+//	defer d.Close()
+//
+//	d.UART.Set(115200, ch347.UARTDataBits8, ch347.UARTParityNone, ch347.UARTStopBitOne)
+//	d.IO.SetSPI(ch347.SPIMode0, ch347.SPIClock1, ch347.SPIByteOrderMSB)
+type Device struct {
+	UART *UART
+	IO   *IO
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// DeviceMetrics is a snapshot of both interfaces counters.
+type DeviceMetrics struct {
+	UART UARTMetrics
+	IO   IOMetrics
+}
+
+// Open returns Device for already opened hidraw devices of the chip.
+// Either of them can be nil if the interface is not used.
+//
+// UART activity is shown with the IO ACT led once IO.ActivityLED is enabled.
+func Open(uartDev, ioDev HIDDev) *Device {
+	d := &Device{}
+
+	if ioDev != nil {
+		d.IO = &IO{Dev: ioDev}
+	}
+
+	if uartDev != nil {
+		d.UART = &UART{Dev: uartDev, ActivityIO: d.IO}
+	}
+
+	return d
+}
+
+// Close closes both interfaces devices, if they implement io.Closer.
+// It waits for operations in progress to finish. Closing twice is a no-op.
+func (d *Device) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+
+	var errs []error
+
+	if d.UART != nil {
+		errs = append(errs, d.UART.Close())
+	}
+
+	if d.IO != nil {
+		d.IO.stopWatchers()
+
+		d.IO.mu.Lock()
+		errs = append(errs, closeDev(d.IO.Dev))
+		d.IO.mu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Metrics returns counters of both interfaces.
+func (d *Device) Metrics() DeviceMetrics {
+	var m DeviceMetrics
+
+	if d.UART != nil {
+		m.UART = d.UART.Metrics()
+	}
+
+	if d.IO != nil {
+		m.IO = d.IO.Metrics()
+	}
+
+	return m
+}
+
+func closeDev(dev HIDDev) error {
+	if cl, ok := dev.(io.Closer); ok {
+		return cl.Close()
+	}
+
+	return nil
+}
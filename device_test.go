@@ -0,0 +1,57 @@
+package ch347
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeviceClose(t *testing.T) {
+	var pins [8]byte
+	uartDev, ioDev := &mockDev{}, &mockDev{respond: gpioResponder(&pins)}
+
+	d := Open(uartDev, ioDev)
+	d.UART.CoalesceDelay = time.Hour
+
+	if _, err := d.UART.Write([]byte("bye")); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := d.IO.WatchPinContext(context.Background(), GPIO3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Buffered UART data is sent, both devices are closed and watchers are stopped.
+	if writes := uartDev.written(); len(writes) != 1 || string(writes[0]) != "\x03\x00bye" {
+		t.Fatalf("uart writes %q", writes)
+	}
+
+	if !uartDev.closed || !ioDev.closed {
+		t.Fatalf("uart closed %v, io closed %v", uartDev.closed, ioDev.closed)
+	}
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			for range events {
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watcher not stopped")
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	// Interface not used.
+	d = Open(uartDev, nil)
+	if err := d.Close(); err != nil || d.IO != nil {
+		t.Fatalf("got %v", err)
+	}
+}
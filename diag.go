@@ -0,0 +1,213 @@
+package ch347
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Diagnostics summarizes current IO state.
+//
+// Chip version is not included: no known HID command reports it. It's only the USB device
+// release number (bcdDevice) of the descriptor, see ch347hid.DeviceInfo.Release.
+type Diagnostics struct {
+	SPIConfigured bool
+	SPIMode       SPIMode
+	SPIClock      SPIClock
+	SPIByteOrder  SPIByteOrder
+
+	I2CConfigured bool
+	I2CMode       I2CMode
+
+	// Raw status bytes of GPIO0-GPIO7 as returned by the device.
+	Pins [8]byte
+
+	// Packet size used to talk to the device.
+	PacketLen int
+}
+
+// String returns human readable diagnostics, ready to be pasted into an issue.
+func (d Diagnostics) String() string {
+	var b strings.Builder
+
+	if d.SPIConfigured {
+		fmt.Fprintf(&b, "SPI: mode %d, clock %d, byte order %d\n", d.SPIMode, d.SPIClock, d.SPIByteOrder)
+	} else {
+		b.WriteString("SPI: not configured\n")
+	}
+
+	if d.I2CConfigured {
+		fmt.Fprintf(&b, "I2C: mode %d\n", d.I2CMode)
+	} else {
+		b.WriteString("I2C: not configured\n")
+	}
+
+	b.WriteString("GPIO:")
+	for i, st := range d.Pins {
+		fmt.Fprintf(&b, " %d=0x%02x", i, st)
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "Packet length: %d\n", d.PacketLen)
+
+	return b.String()
+}
+
+// Diagnostics returns current configuration and GPIO state.
+//
+// GPIO state is read from the device.
+func (c *IO) Diagnostics() (Diagnostics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d := Diagnostics{
+		SPIConfigured: c.spiSet,
+		SPIMode:       c.spiMode,
+		SPIClock:      c.spiClock,
+		SPIByteOrder:  c.spiByteOrder,
+		I2CConfigured: c.i2cSet,
+		I2CMode:       c.i2cMode,
+		PacketLen:     c.packetSize(),
+	}
+
+	var err error
+	d.Pins, err = c.readPins()
+
+	return d, err
+}
+
+// DetectReportSize returns size of input reports the device actually delivers,
+// by reading a GPIO status response into an oversized buffer.
+//
+// Package expects it to be 512 bytes. A smaller size means the OS HID stack
+// truncates reports: I2C transfers, SPI writes and SPIDuplex are split into packets
+// of detected size from now on. SPI reads still need 512 bytes reports.
+func (c *IO) DetectReportSize() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := make([]byte, 2*maxPacketLen)
+	copy(p, []byte{0x0b, 0x00, 0xcc, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+
+	err := c.write(p[:13])
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := c.read(p)
+	if err != nil {
+		return 0, err
+	}
+
+	if p[0] != 0x0b || p[2] != 0xcc {
+		return 0, ErrInvalidResponse
+	}
+
+	c.packetLen = min(max(n, minPacketLen), maxPacketLen)
+
+	return n, nil
+}
+
+// SelfTestCheck is a single SelfTest check result.
+type SelfTestCheck struct {
+	Name string
+	Err  error // Nil if the check passed.
+}
+
+// SelfTestReport lists SelfTest checks results.
+type SelfTestReport []SelfTestCheck
+
+// OK reports whether all checks passed.
+func (r SelfTestReport) OK() bool {
+	for _, ch := range r {
+		if ch.Err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns a line per check like "gpio read: ok".
+func (r SelfTestReport) String() string {
+	var b strings.Builder
+
+	for _, ch := range r {
+		if ch.Err != nil {
+			fmt.Fprintf(&b, "%s: FAIL: %v\n", ch.Name, ch.Err)
+		} else {
+			fmt.Fprintf(&b, "%s: ok\n", ch.Name)
+		}
+	}
+
+	return b.String()
+}
+
+func (r *SelfTestReport) add(name string, err error) {
+	*r = append(*r, SelfTestCheck{Name: name, Err: err})
+}
+
+// SelfTest runs harmless checks to confirm the device is accessible and responding,
+// before running a real program:
+//   - gpio read - pins status request round trip.
+//   - report size - OS delivers full 512 bytes reports.
+//   - spi config, i2c config - current configuration is re-applied, if it was set.
+//
+// Pins, SPI and I2C devices aren't touched, CS isn't driven even with DriveCSIdle set.
+// Failed device access usually means wrong hidraw device or missing permissions on it.
+//
+// Example:
+//
+//	if r := c.SelfTest(); !r.OK() {
+//		fmt.Print(r)
+//	}
+func (c *IO) SelfTest() SelfTestReport {
+	var r SelfTestReport
+
+	_, err := c.ReadAll()
+	r.add("gpio read", err)
+
+	n, err := c.DetectReportSize()
+	if err == nil && n < maxPacketLen {
+		err = fmt.Errorf("reports are truncated to %d bytes", n)
+	}
+	r.add("report size", err)
+
+	if mode, clock, order, ok := c.GetSPI(); ok {
+		// Not SetSPI, which drives CS with DriveCSIdle set.
+		c.mu.Lock()
+		r.add("spi config", c.setSPI(mode, clock, order))
+		c.mu.Unlock()
+	}
+
+	if mode, ok := c.GetI2C(); ok {
+		r.add("i2c config", c.SetI2C(mode))
+	}
+
+	return r
+}
+
+// SelfTest runs harmless checks of UART device:
+//   - read timeout - Dev implements ReadWithTimeout, needed by timeouts and DiscardInput.
+//   - uart config - configuration set by Set is re-applied, if it was set.
+//
+// Received data is left untouched.
+func (c *UART) SelfTest() SelfTestReport {
+	var r SelfTestReport
+
+	var err error
+	if _, ok := c.Dev.(timeoutReader); !ok {
+		err = errors.ErrUnsupported
+	}
+	r.add("read timeout", err)
+
+	c.cfgMu.Lock()
+	set := c.lineCoding != nil
+	c.cfgMu.Unlock()
+
+	if set {
+		r.add("uart config", c.restore())
+	}
+
+	return r
+}
@@ -0,0 +1,191 @@
+package ch347
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelfTestCS(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d, DriveCSIdle: true}
+
+	if err := c.SetSPI(SPIMode0, SPIClock0, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	d.writes = nil
+	c.SelfTest()
+
+	for _, p := range d.written() {
+		if p[2] == 0xc1 {
+			t.Fatal("CS driven")
+		}
+	}
+}
+
+func TestDetectReportSize(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d}
+
+	// Reports truncated to 64 bytes by the OS.
+	report := make([]byte, 64)
+	copy(report, []byte{0x0b, 0x00, 0xcc, 0x08, 0x00})
+	d.queue(report)
+
+	if n, err := c.DetectReportSize(); err != nil || n != 64 {
+		t.Fatalf("got %d, %v", n, err)
+	}
+
+	if diag, _ := c.Diagnostics(); diag.PacketLen != 64 {
+		t.Fatalf("diagnostics packet length %d", diag.PacketLen)
+	}
+
+	d.writes = nil
+
+	w := make([]byte, 5000)
+	if err := c.SPIDuplex(w, make([]byte, len(w))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range d.written() {
+		if len(p) > 64 {
+			t.Fatalf("spi duplex: %d bytes long packet", len(p))
+		}
+	}
+
+	// Write operations are 63 packets max.
+	sw := &spiWriteSim{t: t}
+	c.Dev = sw
+
+	if err := c.SPI(w, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if sw.maxLen > 64 || len(sw.data) != len(w) {
+		t.Fatalf("spi write: %d bytes long packet, %d bytes written", sw.maxLen, len(sw.data))
+	}
+
+	sim := &i2cSim{t: t}
+	c.Dev = sim
+
+	if err := c.I2C(0x50, w[:300], make([]byte, 200)); err != nil {
+		t.Fatal(err)
+	}
+
+	if sim.maxLen > 64 || sim.reads != 200 {
+		t.Fatalf("i2c: %d bytes long packet, %d bytes read", sim.maxLen, sim.reads)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	pins := [8]byte{0x00, 0xc0, 0x80}
+	gpio := gpioResponder(&pins)
+
+	d := &mockDev{respond: func(p []byte) [][]byte {
+		if p[2] == 0xcc {
+			return gpio(p)
+		}
+
+		return spiResponder(p)
+	}}
+	c := &IO{Dev: d}
+
+	diag, err := c.Diagnostics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diag.SPIConfigured || diag.I2CConfigured || diag.Pins != pins || diag.PacketLen != 512 {
+		t.Fatalf("got %+v", diag)
+	}
+
+	if err := c.SetSPI(SPIMode3, SPIClock4, SPIByteOrderLSB); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SetI2C(I2CMode2); err != nil {
+		t.Fatal(err)
+	}
+
+	diag, err = c.Diagnostics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Diagnostics{
+		SPIConfigured: true,
+		SPIMode:       SPIMode3,
+		SPIClock:      SPIClock4,
+		SPIByteOrder:  SPIByteOrderLSB,
+		I2CConfigured: true,
+		I2CMode:       I2CMode2,
+		Pins:          pins,
+		PacketLen:     512,
+	}
+	if diag != want {
+		t.Fatalf("got %+v, want %+v", diag, want)
+	}
+
+	s := diag.String()
+	for _, line := range []string{"SPI: mode 3, clock 4, byte order 1\n", "I2C: mode 2\n", " 1=0xc0 2=0x80 ", "Packet length: 512\n"} {
+		if !strings.Contains(s, line) {
+			t.Fatalf("%q missing from %q", line, s)
+		}
+	}
+}
+
+func TestSelfTestFailures(t *testing.T) {
+	// GPIO responses are garbage, SPI works.
+	d := &mockDev{respond: func(p []byte) [][]byte {
+		if p[2] == 0xcc {
+			return [][]byte{{0x04, 0x00, 0xc0, 0x01, 0x00, 0x00}}
+		}
+
+		return spiResponder(p)
+	}}
+	c := &IO{Dev: d}
+
+	if err := c.SetSPI(SPIMode0, SPIClock0, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	r := c.SelfTest()
+	if r.OK() {
+		t.Fatal("failures not reported")
+	}
+
+	failed := map[string]bool{}
+	for _, ch := range r {
+		failed[ch.Name] = ch.Err != nil
+	}
+
+	want := map[string]bool{"gpio read": true, "report size": true, "spi config": false}
+	if !reflect.DeepEqual(failed, want) {
+		t.Fatalf("got %v, want %v", failed, want)
+	}
+
+	if s := r.String(); !strings.Contains(s, "gpio read: FAIL: ") || !strings.Contains(s, "spi config: ok\n") {
+		t.Fatalf("got %q", s)
+	}
+
+	// Dev can't read with timeout.
+	u := &UART{Dev: plainDev{&mockDev{}}}
+	if err := u.Set(115200, UARTDataBits8, UARTParityNone, UARTStopBitOne); err != nil {
+		t.Fatal(err)
+	}
+
+	r = u.SelfTest()
+	if len(r) != 2 || r[0].Name != "read timeout" || r[0].Err == nil || r[1].Name != "uart config" || r[1].Err != nil {
+		t.Fatalf("got %v", r)
+	}
+}
+
+// plainDev is HIDDev without ReadWithTimeout.
+type plainDev struct {
+	d *mockDev
+}
+
+func (d plainDev) Read(p []byte) (int, error)              { return d.d.Read(p) }
+func (d plainDev) Write(p []byte) (int, error)             { return d.d.Write(p) }
+func (d plainDev) SendFeatureReport(p []byte) (int, error) { return d.d.SendFeatureReport(p) }
@@ -87,27 +87,21 @@ func main() {
 		// Then wait 80ms for measurements to be completed.
 		time.Sleep(80 * time.Millisecond)
 
-		// Then read them.
-		err = c.I2C(addr, nil, r)
+		// Then read them, checking the crc because why not?
+		err = c.I2CCRC(addr, nil, r, 1, ch347.CRC8Trailer)
 		if err != nil {
 			fmt.Println("---", err, time.Now())
 			continue
 		}
 
-		// Check the crc because why not?
-		if r[6] != crc8(r[:6]) {
-			fmt.Println("--- crc check failed", "-", time.Now())
-			continue
-		}
-
 		if r[0] != 0x1c {
 			fmt.Println("--- device is busy", r[0], "-", time.Now())
 			continue
 		}
 
 		// Perform conversion.
-		tRaw = ((uint32(r[3]) & 0x0f) << 16) | (uint32(r[4]) << 8) | uint32(r[5])
-		hRaw = (uint32(r[1]) << 12) | (uint32(r[2]) << 4) | (uint32(r[3]) & 0xf0)
+		tRaw = ch347.BE24(r[3:]) & 0xfffff
+		hRaw = ch347.BE24(r[1:]) >> 4
 
 		t = (float32(tRaw)/0x100000)*200 - 50
 		h = (float32(hRaw) / 0x100000) * 100
@@ -115,21 +109,3 @@ func main() {
 		fmt.Printf("--- %.02f°C - %.02f %% - %v\n", t, h, time.Now())
 	}
 }
-
-func crc8(p []byte) uint8 {
-	crc := uint8(0xff)
-
-	for _, a := range p {
-		crc ^= a
-
-		for i := 8; i > 0; i-- {
-			if crc&0x80 != 0x00 {
-				crc = (crc << 1) ^ 0x31
-			} else {
-				crc = (crc << 1)
-			}
-		}
-	}
-
-	return crc
-}
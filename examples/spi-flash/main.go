@@ -59,12 +59,13 @@ func DevPath(iface int) string {
 }
 
 func main() {
-	var isErase bool
+	var isErase, autoCS bool
 	var toFile, fromFile string
 
 	flag.BoolVar(&isErase, "e", false, "erase flash")
 	flag.StringVar(&toFile, "r", "", "read flash contents to file")
 	flag.StringVar(&fromFile, "w", "", "write flash contents from file")
+	flag.BoolVar(&autoCS, "autocs", false, "let SPI assert CS around transfers instead of SetCS calls (software sequenced, same USB packets)")
 	flag.Parse()
 
 	devPath := DevPath(IO)
@@ -90,13 +91,25 @@ func main() {
 		panic(err)
 	}
 
-	flash := &Flash{c}
+	if autoCS {
+		c.AutoCS = ch347.AutoCS0
+	}
+
+	flash := &Flash{c: c, VerifyWEL: true, AutoCS: autoCS}
 	size := flash.Capacity()
 	if size == 0 {
 		panic("No flash detected")
 	}
 	fmt.Println("Detected flash size:", size, "bytes")
 
+	if mfr, dev, err := flash.ReadManufacturerID(); err == nil {
+		fmt.Printf("Manufacturer ID: 0x%02x, device ID: 0x%02x\n", mfr, dev)
+	}
+
+	if id, err := flash.ReadUniqueID(); err == nil {
+		fmt.Printf("Unique ID: %x\n", id)
+	}
+
 	if isErase {
 		fmt.Println("Erasing flash...")
 
@@ -113,12 +126,13 @@ func main() {
 		fmt.Println("Reading...")
 
 		r := make([]byte, size)
+		start := time.Now()
 		_, err = flash.Read(r)
 		if err != nil {
 			panic(err)
 		}
 
-		fmt.Println("Done!")
+		fmt.Println("Done in", time.Since(start))
 
 		err = os.WriteFile(toFile, r, 0666)
 		if err != nil {
@@ -146,18 +160,41 @@ func main() {
 }
 
 type Flash struct {
-	c *ch347.IO
+	c ch347.SPIBus
+
+	// VerifyWEL makes WriteEnable confirm that write enable latch was set.
+	VerifyWEL bool
+
+	// AutoCS skips SetCS calls, for bus asserting CS by itself (see ch347.IO.AutoCS).
+	AutoCS bool
 }
 
-// Capacity returns flash size by issuing JEDEC ID instruction 0x9f.
-func (f *Flash) Capacity() int {
-	w := []byte{0x9f} // JEDEC ID
-	r := make([]byte, 3)
+// xfer performs SPI transfer within CS assertion.
+func (f *Flash) xfer(w, r []byte) error {
+	if f.AutoCS {
+		return f.c.SPI(w, r)
+	}
 
 	f.c.SetCS(true)
 	err := f.c.SPI(w, r)
 	f.c.SetCS(false)
 
+	return err
+}
+
+// jedecID issues JEDEC ID instruction 0x9f and returns manufacturer, memory type and capacity bytes.
+func (f *Flash) jedecID() ([]byte, error) {
+	w := []byte{0x9f} // JEDEC ID
+	r := make([]byte, 3)
+
+	err := f.xfer(w, r)
+
+	return r, err
+}
+
+// Capacity returns flash size by issuing JEDEC ID instruction 0x9f.
+func (f *Flash) Capacity() int {
+	r, err := f.jedecID()
 	if err != nil {
 		return 0
 	}
@@ -170,44 +207,206 @@ func (f *Flash) Capacity() int {
 	return size
 }
 
+// ReadUniqueID returns 64-bit factory unique ID by issuing 0x4b instruction, followed by 4 dummy bytes.
+func (f *Flash) ReadUniqueID() ([]byte, error) {
+	w := []byte{0x4b, 0x00, 0x00, 0x00, 0x00} // Read unique ID, 4 dummy bytes.
+	r := make([]byte, 8)
+
+	err := f.xfer(w, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ReadManufacturerID returns manufacturer and device ID by issuing 0x90 instruction
+// with 24-bit address 0x000000.
+func (f *Flash) ReadManufacturerID() (mfr, dev uint8, err error) {
+	w := []byte{0x90, 0x00, 0x00, 0x00} // Manufacturer/Device ID, address 0.
+	r := make([]byte, 2)
+
+	err = f.xfer(w, r)
+
+	return r[0], r[1], err
+}
+
 // IsBusy checks status register 1 for busy flag.
 func (f *Flash) IsBusy() bool {
-	w := []byte{0x05} // Read status register.
-	r := make([]byte, 1)
+	st, err := f.ReadStatus()
+	if err != nil {
+		return false
+	}
 
-	f.c.SetCS(true)
-	err := f.c.SPI(w, r)
-	f.c.SetCS(false)
+	return st&0x1 == 1
+}
+
+// ReadStatus reads status register 1 with 0x05 instruction.
+func (f *Flash) ReadStatus() (byte, error) {
+	return f.readReg(0x05)
+}
+
+// statusOps describes how status registers are accessed on chips of a vendor.
+type statusOps struct {
+	read [3]byte // Read instructions for status registers 1-3, 0 if register is missing.
+
+	// Write instructions for status registers 1-3.
+	// Registers sharing the same instruction are written together in a single instruction.
+	write [3]byte
+}
+
+// Status register instructions by JEDEC manufacturer ID.
+var statusOpsByVendor = map[byte]statusOps{
+	0xef: {read: [3]byte{0x05, 0x35, 0x15}, write: [3]byte{0x01, 0x31, 0x11}}, // Winbond.
+	0xc8: {read: [3]byte{0x05, 0x35, 0x15}, write: [3]byte{0x01, 0x31, 0x11}}, // GigaDevice.
+	0xc2: {read: [3]byte{0x05, 0x15, 0x00}, write: [3]byte{0x01, 0x01, 0x00}}, // Macronix, configuration register as SR2.
+}
+
+// Older chips write SR1 and SR2 with a single 0x01 instruction.
+var defaultStatusOps = statusOps{read: [3]byte{0x05, 0x35, 0x15}, write: [3]byte{0x01, 0x01, 0x11}}
 
+// statusOps returns status register instructions for the detected chip.
+func (f *Flash) statusOps() (statusOps, error) {
+	id, err := f.jedecID()
 	if err != nil {
-		return false
+		return statusOps{}, err
+	}
+
+	if ops, ok := statusOpsByVendor[id[0]]; ok {
+		return ops, nil
+	}
+
+	return defaultStatusOps, nil
+}
+
+// ReadStatus2 reads status register 2, which holds quad enable (QE) bit.
+func (f *Flash) ReadStatus2() (byte, error) {
+	return f.readStatusN(1)
+}
+
+// ReadStatus3 reads status register 3.
+func (f *Flash) ReadStatus3() (byte, error) {
+	return f.readStatusN(2)
+}
+
+// readStatusN reads status register n+1 using vendor specific instruction.
+func (f *Flash) readStatusN(n int) (byte, error) {
+	ops, err := f.statusOps()
+	if err != nil {
+		return 0, err
+	}
+
+	if ops.read[n] == 0 {
+		return 0, fmt.Errorf("status register %d is not supported", n+1)
+	}
+
+	return f.readReg(ops.read[n])
+}
+
+// WriteStatusRegisters writes status registers 1-3 and waits for every write to complete.
+//
+// Registers missing on the chip must be 0.
+//
+// Example:
+//
+//	// Set quad enable bit, keeping other bits.
+//	sr1, _ := flash.ReadStatus()
+//	sr2, _ := flash.ReadStatus2()
+//	sr3, _ := flash.ReadStatus3()
+//	err = flash.WriteStatusRegisters(sr1, sr2|0x02, sr3)
+func (f *Flash) WriteStatusRegisters(sr1, sr2, sr3 byte) error {
+	ops, err := f.statusOps()
+	if err != nil {
+		return err
+	}
+
+	sr := [3]byte{sr1, sr2, sr3}
+
+	for i := 0; i < len(sr); {
+		if ops.write[i] == 0 {
+			if sr[i] != 0 {
+				return fmt.Errorf("status register %d is not supported", i+1)
+			}
+
+			i++
+			continue
+		}
+
+		// Collect registers written by the same instruction.
+		cmd := ops.write[i]
+		w := []byte{cmd}
+		for ; i < len(sr) && ops.write[i] == cmd; i++ {
+			w = append(w, sr[i])
+		}
+
+		err = f.WriteEnable(true)
+		if err != nil {
+			return err
+		}
+
+		err = f.xfer(w, nil)
+
+		if err != nil {
+			return err
+		}
+
+		for f.IsBusy() {
+			time.Sleep(1 * time.Millisecond)
+		}
 	}
 
-	return r[0]&0x1 == 1
+	return nil
+}
+
+// readReg reads a single byte register with given instruction.
+func (f *Flash) readReg(cmd byte) (byte, error) {
+	w := []byte{cmd}
+	r := make([]byte, 1)
+
+	err := f.xfer(w, r)
+
+	return r[0], err
 }
 
 // WriteEnable issues write enable 0x06 or write disable 0x04 instruction.
-func (f *Flash) WriteEnable(enable bool) {
+//
+// With VerifyWEL set, write enable latch (status register 1, bit 1) is checked afterwards.
+func (f *Flash) WriteEnable(enable bool) error {
 	w := []byte{0x06} // Write Enable.
 
 	if !enable {
 		w[0] = 0x04 // Write Disable.
 	}
 
-	f.c.SetCS(true)
-	f.c.SPI(w, nil)
-	f.c.SetCS(false)
+	err := f.xfer(w, nil)
+
+	if err != nil || !f.VerifyWEL {
+		return err
+	}
+
+	st, err := f.ReadStatus()
+	if err != nil {
+		return err
+	}
+
+	if (st&0x2 != 0) != enable {
+		return fmt.Errorf("write enable latch is not %v, status 0x%02x", enable, st)
+	}
+
+	return nil
 }
 
 // Erase issues 0xc7 chip erase instruction and waits for it completion.
 func (f *Flash) Erase() error {
-	f.WriteEnable(true)
+	err := f.WriteEnable(true)
+	if err != nil {
+		return err
+	}
 
 	w := []byte{0xc7} // Chip erase.
 
-	f.c.SetCS(true)
-	err := f.c.SPI(w, nil)
-	f.c.SetCS(false)
+	err = f.xfer(w, nil)
 
 	if err != nil {
 		return err
@@ -230,9 +429,7 @@ func (f *Flash) Read(p []byte) (int, error) {
 		byte((addr) & 0xff),
 	}
 
-	f.c.SetCS(true)
-	err := f.c.SPI(w, p)
-	f.c.SetCS(false)
+	err := f.xfer(w, p)
 
 	if err != nil {
 		return 0, err
@@ -241,6 +438,25 @@ func (f *Flash) Read(p []byte) (int, error) {
 	return len(p), nil
 }
 
+// BlockRead reads length bytes starting from addr with a single read data instruction 0x03.
+func (f *Flash) BlockRead(addr, length uint32) ([]byte, error) {
+	w := []byte{
+		0x03,
+		byte((addr >> 16) & 0xff),
+		byte((addr >> 8) & 0xff),
+		byte((addr) & 0xff),
+	}
+	r := make([]byte, length)
+
+	err := f.xfer(w, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
 // Write writes contents to flash by issuing page program instruction 0x02 starting from address 0x000000.
 func (f *Flash) Write(p []byte) (int, error) {
 	addr, dlen := 0, 256 // Up to 256 bytes can be programmed at a time using the Page Program instructions.
@@ -258,11 +474,12 @@ func (f *Flash) Write(p []byte) (int, error) {
 		w[3] = byte((addr) & 0xff)
 		copy(w[4:], p[addr:addr+dlen])
 
-		f.WriteEnable(true)
+		err := f.WriteEnable(true)
+		if err != nil {
+			return addr, err
+		}
 
-		f.c.SetCS(true)
-		err := f.c.SPI(w, nil)
-		f.c.SetCS(false)
+		err = f.xfer(w, nil)
 
 		if err != nil {
 			return addr, err
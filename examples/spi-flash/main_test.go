@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// flashSim is SPI flash keeping three status registers, with vendor specific instructions.
+type flashSim struct {
+	t   *testing.T
+	mfr byte
+
+	reads  map[byte]int   // Status register read by an instruction.
+	writes map[byte][]int // Status registers written by an instruction.
+
+	uid [8]byte
+
+	sr  [3]byte
+	wel bool
+	cs  bool
+	log []byte // Write instructions issued.
+}
+
+func (d *flashSim) SetCS(enable bool) error {
+	d.cs = enable
+	return nil
+}
+
+func (d *flashSim) SPI(w, r []byte) error {
+	if !d.cs {
+		d.t.Fatalf("instruction %#x without CS", w[0])
+	}
+
+	if i, ok := d.reads[w[0]]; ok {
+		r[0] = d.sr[i]
+		return nil
+	}
+
+	if regs, ok := d.writes[w[0]]; ok {
+		if !d.wel {
+			d.t.Fatalf("instruction %#x without write enable", w[0])
+		}
+
+		if len(w) != 1+len(regs) {
+			d.t.Fatalf("instruction %#x with %d bytes", w[0], len(w)-1)
+		}
+
+		for k, i := range regs {
+			d.sr[i] = w[1+k]
+		}
+
+		d.wel = false
+		d.log = append(d.log, w[0])
+
+		return nil
+	}
+
+	switch w[0] {
+	case 0x9f: // JEDEC ID.
+		copy(r, []byte{d.mfr, 0x40, 0x18})
+	case 0x4b: // Unique ID, after 4 dummy bytes.
+		if len(w) != 5 || len(r) != 8 {
+			d.t.Fatalf("unique id: % x, %d bytes read", w, len(r))
+		}
+
+		copy(r, d.uid[:])
+	case 0x90: // Manufacturer and device ID, after 24-bit address 0.
+		if !bytes.Equal(w, []byte{0x90, 0x00, 0x00, 0x00}) || len(r) != 2 {
+			d.t.Fatalf("manufacturer id: % x, %d bytes read", w, len(r))
+		}
+
+		copy(r, []byte{d.mfr, 0x17})
+	case 0x06:
+		d.wel = true
+	case 0x04:
+		d.wel = false
+	default:
+		d.t.Fatalf("unexpected instruction %#x", w[0])
+	}
+
+	return nil
+}
+
+func TestStatusRegisters(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		sim   *flashSim
+		sr    [3]byte
+		log   []byte
+		noSR3 bool
+	}{
+		{
+			name: "winbond",
+			sim: &flashSim{mfr: 0xef,
+				reads:  map[byte]int{0x05: 0, 0x35: 1, 0x15: 2},
+				writes: map[byte][]int{0x01: {0}, 0x31: {1}, 0x11: {2}}},
+			sr:  [3]byte{0x1c, 0x02, 0x60},
+			log: []byte{0x01, 0x31, 0x11},
+		},
+		{
+			name: "macronix",
+			sim: &flashSim{mfr: 0xc2,
+				reads:  map[byte]int{0x05: 0, 0x15: 1},
+				writes: map[byte][]int{0x01: {0, 1}}},
+			sr:    [3]byte{0x40, 0x07, 0x00},
+			log:   []byte{0x01},
+			noSR3: true,
+		},
+		{
+			name: "unknown",
+			sim: &flashSim{mfr: 0x01,
+				reads:  map[byte]int{0x05: 0, 0x35: 1, 0x15: 2},
+				writes: map[byte][]int{0x01: {0, 1}, 0x11: {2}}},
+			sr:  [3]byte{0x1c, 0x02, 0x60},
+			log: []byte{0x01, 0x11},
+		},
+	} {
+		tc.sim.t = t
+		f := &Flash{c: tc.sim}
+
+		if err := f.WriteStatusRegisters(tc.sr[0], tc.sr[1], tc.sr[2]); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		if tc.sim.sr != tc.sr || !reflect.DeepEqual(tc.sim.log, tc.log) {
+			t.Fatalf("%s: registers % x, instructions % x", tc.name, tc.sim.sr, tc.sim.log)
+		}
+
+		sr1, err1 := f.ReadStatus()
+		sr2, err2 := f.ReadStatus2()
+		if err1 != nil || err2 != nil || sr1 != tc.sr[0] || sr2 != tc.sr[1] {
+			t.Fatalf("%s: read %#x %#x, %v %v", tc.name, sr1, sr2, err1, err2)
+		}
+
+		sr3, err := f.ReadStatus3()
+		if tc.noSR3 {
+			if err == nil {
+				t.Fatalf("%s: missing status register 3 read", tc.name)
+			}
+
+			if err := f.WriteStatusRegisters(0, 0, 0x01); err == nil {
+				t.Fatalf("%s: missing status register 3 written", tc.name)
+			}
+
+			continue
+		}
+
+		if err != nil || sr3 != tc.sr[2] {
+			t.Fatalf("%s: status register 3 %#x, %v", tc.name, sr3, err)
+		}
+	}
+}
+
+func TestIDs(t *testing.T) {
+	sim := &flashSim{t: t, mfr: 0xef, uid: [8]byte{0xd2, 0x64, 0x1c, 0x1b, 0x43, 0x2a, 0x30, 0x2f}}
+	f := &Flash{c: sim}
+
+	uid, err := f.ReadUniqueID()
+	if err != nil || !bytes.Equal(uid, sim.uid[:]) {
+		t.Fatalf("unique id % x, %v", uid, err)
+	}
+
+	mfr, dev, err := f.ReadManufacturerID()
+	if err != nil || mfr != 0xef || dev != 0x17 {
+		t.Fatalf("manufacturer %#x, device %#x, %v", mfr, dev, err)
+	}
+
+	if sim.cs {
+		t.Fatal("CS left asserted")
+	}
+}
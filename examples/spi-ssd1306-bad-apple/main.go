@@ -131,6 +131,7 @@ func main() {
 // SSD1306 implements WriteCloser interface.
 type SSD1306 struct {
 	c           *ch347.IO
+	pl          *ch347.SPIPlayer
 	buf         []byte
 	x, y        int
 	nextFrameAt time.Time
@@ -147,6 +148,12 @@ func NewSSD1306(c *ch347.IO, fps int) (*SSD1306, error) {
 	const RST = ch347.GPIO5 // SCS1
 	const DC = ch347.GPIO1  // MISO
 
+	// CS1 is used as plain GPIO, make sure it's never used as hardware CS.
+	err := c.AssignPin(RST, ch347.PinRoleGPIO)
+	if err != nil {
+		return nil, err
+	}
+
 	// Trigger RST sequence.
 	c.WritePin(RST, true, true)
 	time.Sleep(1 * time.Millisecond)
@@ -182,7 +189,7 @@ func NewSSD1306(c *ch347.IO, fps int) (*SSD1306, error) {
 	}
 
 	c.SetCS(true)
-	err := c.SPI(w, nil)
+	err = c.SPI(w, nil)
 	c.SetCS(false)
 
 	if err != nil {
@@ -200,6 +207,7 @@ func NewSSD1306(c *ch347.IO, fps int) (*SSD1306, error) {
 
 	return &SSD1306{
 		c:         c,
+		pl:        ch347.NewSPIPlayer(c, 0),
 		buf:       make([]byte, 128*8),
 		frameTime: ft,
 	}, nil
@@ -242,10 +250,10 @@ func (w *SSD1306) Write(p []byte) (int, error) {
 
 // Close displays any remaining buffer.
 func (w *SSD1306) Close() error {
-	if w.x == 0 && w.y == 0 {
-		return nil
+	if w.x != 0 || w.y != 0 {
+		w.display()
 	}
-	return w.display()
+	return w.pl.Close()
 }
 
 func (w *SSD1306) display() error {
@@ -257,9 +265,6 @@ func (w *SSD1306) display() error {
 		w.nextFrameAt = time.Now().Add(w.frameTime)
 	}
 
-	w.c.SetCS(true)
-	err := w.c.SPI(w.buf, nil)
-	w.c.SetCS(false)
-
-	return err
+	// Frame is copied, so the next one can be drawn while this one is being sent.
+	return w.pl.Submit(w.buf)
 }
@@ -12,7 +12,6 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"time"
 
 	"github.com/serfreeman1337/go-ch347"
@@ -117,7 +116,7 @@ type PZEM004Reading struct {
 }
 
 type PZEM004 struct {
-	dev io.ReadWriter
+	dev *ch347.UART
 }
 
 func (pzem *PZEM004) ReadAll(r *PZEM004Reading) error {
@@ -125,68 +124,47 @@ func (pzem *PZEM004) ReadAll(r *PZEM004Reading) error {
 	const regAddr uint16 = 0x0000 // Modbus register address.
 	const count uint16 = 0x09     // Number of regs.
 
-	const rlen = count*2 + 5
-
-	p := make([]byte, 0, rlen)
+	const rlen = int(count)*2 + 5
 
 	// Modbus request payload.
-	p = append(p,
-		serverAddr,
-		0x04,                  // Read input register.
-		byte(regAddr>>8)&0xff, // Reg Addr MSB,
-		byte(regAddr)&0xff,    // Reg Addr LSB.
-		byte(count>>8)&0xff,   // Number of Reg MSB
-		byte(count)&0xff,      // Number of Reg LSB
+	p := ch347.BuildModbusRequest(serverAddr,
+		0x04, // Read input register.
+		[]byte{
+			byte(regAddr>>8) & 0xff, // Reg Addr MSB,
+			byte(regAddr) & 0xff,    // Reg Addr LSB.
+			byte(count>>8) & 0xff,   // Number of Reg MSB
+			byte(count) & 0xff,      // Number of Reg LSB
+		},
 	)
 
-	crc := crc16(p)
-	p = append(p, byte(crc)&0xff, byte(crc>>8)&0xff)
-
 	_, err := pzem.dev.Write(p)
 	if err != nil {
 		return err
 	}
 
-	// Modbus response payload.
-	p = p[:rlen]
-	_, err = pzem.dev.Read(p)
+	// Modbus response payload, with 2 bytes CRC confirmed.
+	// Shorter exception response ends up with ErrTimeout.
+	p, err = pzem.dev.ReadCRCFrame(rlen, 2, func(p []byte) []byte {
+		crc := ch347.CRC16Modbus(p)
+		return []byte{byte(crc), byte(crc >> 8)}
+	}, 1*time.Second)
 	if err != nil {
 		return err
 	}
 
-	// Confirm response CRC.
-	crc = crc16(p[:len(p)-2])
-
-	if p[len(p)-2] != byte(crc&0xff) || p[len(p)-1] != byte(crc>>8)&0xff {
-		return fmt.Errorf("crc check failed")
+	// Check for exception response.
+	_, _, err = ch347.ParseModbusResponse(p)
+	if err != nil {
+		return err
 	}
 
-	// I'm sorry.
-	r.V = float32((uint32(p[3])<<8)|uint32(p[4])) / 10.0
-	r.A = float32((((uint32(p[7])<<8)|uint32(p[8]))<<16)|((uint32(p[5])<<8)|uint32(p[6]))) / 1000.0
-	r.W = float32((((uint32(p[11])<<8)|uint32(p[12]))<<16)|((uint32(p[9])<<8)|uint32(p[10]))) / 10.0
-	r.Wh = float32((((uint32(p[15]) << 8) | uint32(p[16])) << 16) | ((uint32(p[13]) << 8) | uint32(p[14])))
-	r.F = float32((uint32(p[17])<<8)|uint32(p[18])) / 10.0
-	r.Pf = float32((uint32(p[19])<<8)|uint32(p[20])) / 100.0
+	// 32-bit values are sent as low word first.
+	r.V = float32(ch347.BE16(p[3:])) / 10.0
+	r.A = float32(ch347.BE16(p[7:])<<16|ch347.BE16(p[5:])) / 1000.0
+	r.W = float32(ch347.BE16(p[11:])<<16|ch347.BE16(p[9:])) / 10.0
+	r.Wh = float32(ch347.BE16(p[15:])<<16 | ch347.BE16(p[13:]))
+	r.F = float32(ch347.BE16(p[17:])) / 10.0
+	r.Pf = float32(ch347.BE16(p[19:])) / 100.0
 
 	return nil
 }
-
-func crc16(p []byte) uint16 {
-	crc := uint16(0xffff)
-
-	for _, a := range p {
-		crc ^= uint16(a)
-
-		for i := 8; i != 0; i-- {
-			if (crc & 0x0001) != 0 {
-				crc >>= 1
-				crc ^= 0xA001
-			} else {
-				crc >>= 1
-			}
-		}
-	}
-
-	return crc
-}
@@ -1,6 +1,10 @@
 package ch347
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Pin represents available pins for GPIO operations.
 type Pin uint8
@@ -31,6 +35,18 @@ const (
 	GPIO7
 )
 
+// GPIOStrictness represents WritePin response validation mode.
+type GPIOStrictness uint8
+
+const (
+	// GPIOStrict confirms pin direction and level bits in the device response.
+	GPIOStrict GPIOStrictness = iota
+
+	// GPIOLenient only checks the command echo of the device response.
+	// Use it if WritePin reports false "gpio set as ... failed" errors on your firmware.
+	GPIOLenient
+)
+
 // WritePin sets given pin operation mode.
 //
 // Example:
@@ -54,11 +70,70 @@ const (
 //		time.Sleep(100*time.Miliseconds)
 //	}
 func (c *IO) WritePin(pin Pin, output bool, level bool) error {
+	if c.GPIOCoalesce > 0 {
+		err := c.writePinBatched(pin, output, level)
+		c.metrics.done(&c.metrics.gpioOps, 1, err)
+
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	//		CMD	 LEN? 	PINS
-	// 0b00  cc	08 00	c8 00 08 08 00 08 08 08
+	err := c.writePin(pin, output, level)
+	c.metrics.done(&c.metrics.gpioOps, 1, err)
+
+	return err
+}
+
+func (c *IO) writePin(pin Pin, output bool, level bool) error {
+	if err := c.checkPinRole(pin, PinRoleGPIO); err != nil {
+		return err
+	}
+
+	var set [8]byte
+	set[pin] = pinSetByte(output, level)
+
+	return c.writePins(set)
+}
+
+// PinState is a pin direction and output level set by WritePins.
+type PinState struct {
+	Output bool
+	Level  bool
+}
+
+// WritePins sets several pins with a single packet. Pins not in updates are left untouched.
+//
+// Example:
+//
+//	// Set DC and release RST at once.
+//	err := c.WritePins(map[ch347.Pin]ch347.PinState{
+//		ch347.GPIO1: {Output: true, Level: true},
+//		ch347.GPIO5: {Output: true, Level: true},
+//	})
+func (c *IO) WritePins(updates map[Pin]PinState) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var set [8]byte
+
+	for pin, st := range updates {
+		if err := c.checkPinRole(pin, PinRoleGPIO); err != nil {
+			return err
+		}
+
+		set[pin] = pinSetByte(st.Output, st.Level)
+	}
+
+	err := c.writePins(set)
+	c.metrics.done(&c.metrics.gpioOps, 1, err)
+
+	return err
+}
+
+// pinSetByte returns pin byte of GPIO set command.
+func pinSetByte(output bool, level bool) byte {
 	// Pins:
 	// 00 - 00000000 - ignore ?
 	// 08 - 00001000 - disabled ?
@@ -66,33 +141,41 @@ func (c *IO) WritePin(pin Pin, output bool, level bool) error {
 	// c8 - 11001000 - enabled / input / ?
 	// f0 - 11110000 - enabled / output / off
 	// f8 - 11111000 - enabled / output / on
-	p := []byte{0x0b, 0x00, 0xcc, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
-
-	pos := 5 + pin
-
 	if output {
 		if level {
-			p[pos] = 0xf8
-		} else {
-			p[pos] = 0xf0
+			return 0xf8
 		}
-	} else {
-		p[pos] = 0xc0
+		return 0xf0
 	}
 
-	_, err := c.Dev.Write(p)
+	return 0xc0
+}
+
+// writePins sets all pins in one packet. Pins with zero byte in set are left untouched.
+func (c *IO) writePins(set [8]byte) error {
+	return c.resync(func() error { return c.writePinsOnce(set) })
+}
+
+func (c *IO) writePinsOnce(set [8]byte) error {
+	//		CMD	 LEN? 	PINS
+	// 0b00  cc	08 00	c8 00 08 08 00 08 08 08
+	p := []byte{0x0b, 0x00, 0xcc, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	copy(p[5:], set[:])
+
+	err := c.write(p)
 	if err != nil {
 		return err
 	}
 
 	// Device returns whole gpio status.
-	_, err = c.Dev.Read(p)
+	_, err = c.read(p)
 	if err != nil {
 		return err
 	}
 
 	if p[0] != 0x0b || p[2] != 0xcc {
-		return fmt.Errorf("invaid response. expected (0x%02x 0x%02x 0x%02x), got (0x%02x 0x%02x 0x%02x)",
+		return fmt.Errorf("%w. expected (0x%02x 0x%02x 0x%02x), got (0x%02x 0x%02x 0x%02x)",
+			ErrInvalidResponse,
 			0x0b, 0x00, 0xcc,
 			p[0], p[1], p[2],
 		)
@@ -103,24 +186,36 @@ func (c *IO) WritePin(pin Pin, output bool, level bool) error {
 	// 80 = 10000000 // output off
 	// c0 = 11000000 // output on
 
-	// Confirm pin state.
-	err = nil
-	if output {
-		mask := byte(0x80) // Check bit 7 for output.
-		if level {
-			mask |= 0x40 // Check bit 6 for output level.
-		}
+	if c.GPIOStrictness == GPIOLenient {
+		return nil
+	}
 
-		if p[pos]&mask == 0x00 {
-			err = fmt.Errorf("gpio set as output failed, got 0x%02x", p[pos])
+	// Confirm pins state.
+	for i, b := range set {
+		if b == 0x00 {
+			continue
 		}
-	} else {
-		if p[pos]&0x80 != 0x00 { // Bit 7 is still set (this pin is still output) ?
-			err = fmt.Errorf("gpio set as input failed, got 0x%02x", p[pos])
+
+		pos := 5 + i
+		output, level := b == 0xf0 || b == 0xf8, b == 0xf8
+
+		if output {
+			mask := byte(0x80) // Check bit 7 for output.
+			if level {
+				mask |= 0x40 // Check bit 6 for output level.
+			}
+
+			if p[pos]&mask == 0x00 {
+				return fmt.Errorf("gpio set as output failed, got 0x%02x", p[pos])
+			}
+		} else {
+			if p[pos]&0x80 != 0x00 { // Bit 7 is still set (this pin is still output) ?
+				return fmt.Errorf("gpio set as input failed, got 0x%02x", p[pos])
+			}
 		}
 	}
 
-	return err
+	return nil
 }
 
 // ReadPin returns given pin level.
@@ -129,45 +224,253 @@ func (c *IO) WritePin(pin Pin, output bool, level bool) error {
 //
 // For input pin "true" means this pin is shorted to GND.
 func (c *IO) ReadPin(pin Pin) (bool, error) {
+	if err := checkPin(pin); err != nil {
+		return false, err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	st, err := c.readPins()
+	c.metrics.done(&c.metrics.gpioOps, 1, err)
+	if err != nil {
+		return false, err
+	}
+
+	return decodePin(st[pin]).Level, nil
+}
+
+// PinDirection represents pin direction.
+type PinDirection uint8
+
+const (
+	PinInput PinDirection = iota
+	PinOutput
+)
+
+// PinReading is a decoded pin status.
+type PinReading struct {
+	Direction PinDirection
+
+	// Level has the same meaning as ReadPin result.
+	Level bool
+
+	// Raw status byte returned by the device.
+	Raw byte
+}
+
+// ReadPinDetailed returns given pin direction, level and raw status byte.
+func (c *IO) ReadPinDetailed(pin Pin) (PinReading, error) {
+	if err := checkPin(pin); err != nil {
+		return PinReading{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st, err := c.readPins()
+	c.metrics.done(&c.metrics.gpioOps, 1, err)
+	if err != nil {
+		return PinReading{}, err
+	}
+
+	return decodePin(st[pin]), nil
+}
+
+func (d PinDirection) String() string {
+	if d == PinOutput {
+		return "out"
+	}
+
+	return "in"
+}
+
+// AllPins is decoded status of GPIO0-GPIO7.
+type AllPins [8]PinReading
+
+// Direction returns given pin direction.
+func (a AllPins) Direction(pin Pin) PinDirection {
+	return a[pin].Direction
+}
+
+// Level returns given pin level, see ReadPin.
+func (a AllPins) Level(pin Pin) bool {
+	return a[pin].Level
+}
+
+// String returns pins status like "GPIO0=out:1 GPIO1=in:0 ...".
+func (a AllPins) String() string {
+	var b strings.Builder
+
+	for i, r := range a {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+
+		lvl := 0
+		if r.Level {
+			lvl = 1
+		}
+
+		fmt.Fprintf(&b, "GPIO%d=%s:%d", i, r.Direction, lvl)
+	}
+
+	return b.String()
+}
+
+// ReadAll returns direction and level of every pin with a single request.
+func (c *IO) ReadAll() (AllPins, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var a AllPins
+
+	st, err := c.readPins()
+	c.metrics.done(&c.metrics.gpioOps, 1, err)
+	if err != nil {
+		return a, err
+	}
+
+	for i, raw := range st {
+		a[i] = decodePin(raw)
+	}
+
+	return a, nil
+}
+
+// decodePin decodes raw pin status byte.
+func decodePin(raw byte) PinReading {
+	// 00 = 00000000 // input on ?
+	// 40 = 01000000 // input off ?
+	// 80 = 10000000 // output off
+	// c0 = 11000000 // output on
+	r := PinReading{Raw: raw}
+
+	if raw&0x80 != 0x00 { // Pin is output.
+		r.Direction = PinOutput
+		r.Level = raw&0x40 != 0x00 // Pin level is high.
+	} else { // Pin is input.
+		r.Level = raw&0x40 == 0x00 // Pin level is low ?
+	}
+
+	return r
+}
+
+// readPins returns raw status bytes of all pins.
+func (c *IO) readPins() (st [8]byte, err error) {
+	err = c.resync(func() error {
+		st, err = c.readPinsOnce()
+		return err
+	})
+
+	return st, err
+}
+
+func (c *IO) readPinsOnce() ([8]byte, error) {
+	var st [8]byte
+
 	p := []byte{0x0b, 0x00, 0xcc, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
 
-	_, err := c.Dev.Write(p)
+	err := c.write(p)
 	if err != nil {
-		return false, err
+		return st, err
 	}
 
-	_, err = c.Dev.Read(p)
+	_, err = c.read(p)
 	if err != nil {
-		return false, err
+		return st, err
 	}
 
 	if p[0] != 0x0b || p[2] != 0xcc {
-		return false, fmt.Errorf("invaid response. expected (0x%02x 0x%02x 0x%02x), got (0x%02x 0x%02x 0x%02x)",
+		return st, fmt.Errorf("%w. expected (0x%02x 0x%02x 0x%02x), got (0x%02x 0x%02x 0x%02x)",
+			ErrInvalidResponse,
 			0x0b, 0x00, 0xcc,
 			p[0], p[1], p[2],
 		)
 	}
 
-	pos := 5 + pin
+	copy(st[:], p[5:])
+	return st, nil
+}
 
-	// 00 = 00000000 // input on ?
-	// 40 = 01000000 // input off ?
-	// 80 = 10000000 // output off
-	// c0 = 11000000 // output on
-	if p[pos]&0x80 != 0x00 { // Pin is output.
-		if p[pos]&0x40 != 0x00 { // Pin level is high.
-			return true, nil
-		} else {
-			return false, nil
+// PinStep is a single pin change of WritePinSequence.
+type PinStep struct {
+	Pin    Pin
+	Output bool
+	Level  bool
+
+	// Delay after the change.
+	Delay time.Duration
+}
+
+// WritePinSequence applies pin changes strictly in the given order, each with its own packet,
+// as bit-banged protocols require (set data, then pulse clock).
+//
+// Every step costs an USB round trip (about 1ms), on top of its delay.
+//
+// Example:
+//
+//	// Shift a bit out: set data and pulse clock.
+//	err := c.WritePinSequence([]ch347.PinStep{
+//		{Pin: ch347.GPIO6, Output: true, Level: bit},
+//		{Pin: ch347.GPIO7, Output: true, Level: true},
+//		{Pin: ch347.GPIO7, Output: true, Level: false},
+//	})
+func (c *IO) WritePinSequence(steps []PinStep) error {
+	for _, st := range steps {
+		err := c.WritePin(st.Pin, st.Output, st.Level)
+		if err != nil {
+			return err
 		}
-	} else { // Pin is input.
-		if p[pos]&0x40 != 0x00 { // Pin level is low ?
-			return false, nil
-		} else {
-			return true, nil
+
+		if st.Delay > 0 {
+			time.Sleep(st.Delay)
+		}
+	}
+
+	return nil
+}
+
+// SetActivityLED turns ACT led (GPIO4) on or off.
+func (c *IO) SetActivityLED(on bool) error {
+	return c.WritePin(GPIO4, true, on)
+}
+
+// activity toggles ACT led if ActivityLED is enabled.
+// Must be called with c.mu held.
+func (c *IO) activity() {
+	if !c.ActivityLED {
+		return
+	}
+
+	c.activityOps++
+	c.writePin(GPIO4, true, c.activityOps&1 == 1) // Errors are ignored, led is just an indicator.
+}
+
+// Tone outputs square wave with given frequency on pin for duration d. Pin is left low afterwards.
+//
+// CH347 has no known hardware tone or PWM generator in HIDAPI mode,
+// so the wave is software generated. Every edge costs an USB round trip,
+// so expect frequencies above few hundreds Hz to be inaccurate.
+func (c *IO) Tone(pin Pin, freqHz float64, d time.Duration) error {
+	if freqHz <= 0 {
+		return fmt.Errorf("invalid frequency %f", freqHz)
+	}
+
+	half := time.Duration(float64(time.Second) / freqHz / 2)
+	level := true
+	end := time.Now().Add(d)
+
+	for next := time.Now(); next.Before(end); next = next.Add(half) {
+		time.Sleep(time.Until(next))
+
+		err := c.WritePin(pin, true, level)
+		if err != nil {
+			return err
 		}
+
+		level = !level
 	}
+
+	return c.WritePin(pin, true, false)
 }
@@ -0,0 +1,320 @@
+package ch347
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWritePins(t *testing.T) {
+	var pins [8]byte
+	d := &mockDev{respond: gpioResponder(&pins)}
+	c := &IO{Dev: d}
+
+	err := c.WritePins(map[Pin]PinState{
+		GPIO1: {Output: true, Level: true},
+		GPIO4: {Output: true},
+		GPIO6: {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{0x0b, 0x00, 0xcc, 0x08, 0x00, 0x00, 0xf8, 0x00, 0x00, 0xf0, 0x00, 0xc0, 0x00}
+	if writes := d.written(); len(writes) != 1 || !bytes.Equal(writes[0], want) {
+		t.Fatalf("got % x, want % x", writes, want)
+	}
+}
+
+func TestGPIOStrictness(t *testing.T) {
+	// Device ignoring the request, pin stays input.
+	d := &mockDev{respond: func(p []byte) [][]byte {
+		return [][]byte{{0x0b, 0x00, 0xcc, 0x08, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}}
+	}}
+
+	c := &IO{Dev: d}
+	if err := c.WritePin(GPIO4, true, true); err == nil {
+		t.Fatal("GPIOStrict accepted unchanged pin")
+	}
+
+	c.GPIOStrictness = GPIOLenient
+	if err := c.WritePin(GPIO4, true, true); err != nil {
+		t.Fatalf("GPIOLenient: %v", err)
+	}
+}
+
+func TestWritePinCoalesce(t *testing.T) {
+	var pins [8]byte
+	d := &mockDev{respond: gpioResponder(&pins)}
+	c := &IO{Dev: d, GPIOCoalesce: 30 * time.Millisecond}
+
+	var wg sync.WaitGroup
+	write := func(delay time.Duration, pin Pin, level bool) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			time.Sleep(delay)
+			if err := c.WritePin(pin, true, level); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	write(0, GPIO1, true)
+	write(5*time.Millisecond, GPIO2, true)
+	write(10*time.Millisecond, GPIO2, true) // Same change, joins.
+	write(10*time.Millisecond, GPIO1, false)
+	wg.Wait()
+
+	// GPIO1 high and low aren't merged, low goes to the next request.
+	writes := d.written()
+	if len(writes) != 2 {
+		t.Fatalf("%d requests: % x", len(writes), writes)
+	}
+
+	if got, want := writes[0][5:], []byte{0x00, 0xf8, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00}; !bytes.Equal(got, want) {
+		t.Fatalf("first request % x, want % x", got, want)
+	}
+
+	if got, want := writes[1][5:], []byte{0x00, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}; !bytes.Equal(got, want) {
+		t.Fatalf("second request % x, want % x", got, want)
+	}
+}
+
+func TestActivityLED(t *testing.T) {
+	var pins [8]byte
+	gpio := gpioResponder(&pins)
+
+	d := &mockDev{respond: func(p []byte) [][]byte {
+		if p[2] == 0xcc {
+			return gpio(p)
+		}
+
+		return spiResponder(p)
+	}}
+	c := &IO{Dev: d}
+	u := &UART{Dev: &mockDev{}, ActivityIO: c}
+
+	// Off by default.
+	if err := c.SPI([]byte{0x9f}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.written()) != 1 {
+		t.Fatal("led toggled with ActivityLED off")
+	}
+
+	c.ActivityLED = true
+	d.writes = nil
+
+	c.SPI([]byte{0x9f}, nil)
+	c.SPI([]byte{0x9f}, nil)
+	u.Write([]byte("a"))
+
+	// Led packet goes before every operation, toggling the led.
+	var leds []byte
+	for _, p := range d.written() {
+		if p[2] == 0xcc {
+			leds = append(leds, p[5+GPIO4])
+		}
+	}
+
+	if want := []byte{0xf8, 0xf0, 0xf8}; !bytes.Equal(leds, want) {
+		t.Fatalf("led set bytes % x, want % x", leds, want)
+	}
+
+	if err := c.SetActivityLED(false); err != nil || pins[GPIO4] != 0x80 {
+		t.Fatalf("SetActivityLED: %v, pin status 0x%02x", err, pins[GPIO4])
+	}
+}
+
+func TestTone(t *testing.T) {
+	var pins [8]byte
+	d := &mockDev{respond: gpioResponder(&pins)}
+	c := &IO{Dev: d}
+
+	// 100Hz for 50ms is 10 edges, 5ms apart.
+	start := time.Now()
+	if err := c.Tone(GPIO2, 100, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Fatalf("took %v", elapsed)
+	}
+
+	writes := d.written()
+	if len(writes) < 10 || len(writes) > 12 {
+		t.Fatalf("%d packets written", len(writes))
+	}
+
+	// Alternating high and low levels, ending low.
+	for i, p := range writes {
+		want := []byte{0x0b, 0x00, 0xcc, 0x08, 0x00, 0x00, 0x00, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00}
+		if i%2 == 1 || i == len(writes)-1 {
+			want[7] = 0xf0
+		}
+
+		if !bytes.Equal(p, want) {
+			t.Fatalf("packet %d: got % x, want % x", i, p, want)
+		}
+	}
+
+	if err := c.Tone(GPIO2, 0, time.Second); err == nil {
+		t.Fatal("zero frequency accepted")
+	}
+}
+
+// stuckDev never responds: Read blocks forever, ReadWithTimeout waits out the timeout.
+type stuckDev struct {
+	mockDev
+}
+
+func (d *stuckDev) Read(p []byte) (int, error) {
+	select {}
+}
+
+func (d *stuckDev) ReadWithTimeout(p []byte, timeout time.Duration) (int, error) {
+	time.Sleep(timeout)
+	return 0, nil
+}
+
+func TestGPIOReadTimeout(t *testing.T) {
+	c := &IO{Dev: &stuckDev{}, ReadTimeout: 10 * time.Millisecond}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		if err := c.WritePin(GPIO1, true, true); !errors.Is(err, ErrTimeout) {
+			t.Errorf("WritePin: got %v, want ErrTimeout", err)
+		}
+
+		if _, err := c.ReadPin(GPIO1); !errors.Is(err, ErrTimeout) {
+			t.Errorf("ReadPin: got %v, want ErrTimeout", err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GPIO call blocked")
+	}
+}
+
+func TestReadPinDetailed(t *testing.T) {
+	for _, tc := range []struct {
+		raw  byte
+		want PinReading
+	}{
+		{0x00, PinReading{Direction: PinInput, Level: true, Raw: 0x00}}, // Input shorted to GND.
+		{0x40, PinReading{Direction: PinInput, Level: false, Raw: 0x40}},
+		{0x80, PinReading{Direction: PinOutput, Level: false, Raw: 0x80}},
+		{0xc0, PinReading{Direction: PinOutput, Level: true, Raw: 0xc0}},
+	} {
+		var pins [8]byte
+		pins[GPIO3] = tc.raw
+
+		c := &IO{Dev: &mockDev{respond: gpioResponder(&pins)}}
+
+		r, err := c.ReadPinDetailed(GPIO3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r != tc.want {
+			t.Fatalf("0x%02x: got %+v, want %+v", tc.raw, r, tc.want)
+		}
+	}
+
+	c := &IO{Dev: &mockDev{}}
+	if _, err := c.ReadPinDetailed(Pin(8)); err == nil {
+		t.Fatal("pin 8 accepted")
+	}
+}
+
+func TestWritePinSequence(t *testing.T) {
+	var pins [8]byte
+	d := &mockDev{respond: gpioResponder(&pins)}
+	c := &IO{Dev: d}
+
+	steps := []PinStep{
+		{Pin: GPIO6, Output: true, Level: true},
+		{Pin: GPIO7, Output: true, Level: true, Delay: 20 * time.Millisecond},
+		{Pin: GPIO7, Output: true, Level: false},
+		{Pin: GPIO6, Output: false},
+	}
+
+	start := time.Now()
+	if err := c.WritePinSequence(steps); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("took %v, delay skipped", elapsed)
+	}
+
+	// A packet per step, touching only the step's pin.
+	writes := d.written()
+	if len(writes) != len(steps) {
+		t.Fatalf("%d packets written, want %d", len(writes), len(steps))
+	}
+
+	for i, st := range steps {
+		want := make([]byte, 13)
+		copy(want, []byte{0x0b, 0x00, 0xcc, 0x08, 0x00})
+		want[5+st.Pin] = pinSetByte(st.Output, st.Level)
+
+		if !bytes.Equal(writes[i], want) {
+			t.Fatalf("step %d: got % x, want % x", i, writes[i], want)
+		}
+	}
+
+	// Sequence stops at the first failing step.
+	d.writes = nil
+	if err := c.WritePinSequence([]PinStep{{Pin: Pin(8), Output: true}, {Pin: GPIO6, Output: true}}); err == nil {
+		t.Fatal("pin 8 accepted")
+	}
+
+	if n := len(d.written()); n != 0 {
+		t.Fatalf("%d packets written after failed step", n)
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	for _, tc := range []struct {
+		raw  [8]byte
+		want string
+	}{
+		{[8]byte{}, "GPIO0=in:1 GPIO1=in:1 GPIO2=in:1 GPIO3=in:1 GPIO4=in:1 GPIO5=in:1 GPIO6=in:1 GPIO7=in:1"},
+		{[8]byte{0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40}, "GPIO0=in:0 GPIO1=in:0 GPIO2=in:0 GPIO3=in:0 GPIO4=in:0 GPIO5=in:0 GPIO6=in:0 GPIO7=in:0"},
+		{[8]byte{0xc0, 0x80, 0x00, 0x40, 0xc0, 0xc0, 0x80, 0x40}, "GPIO0=out:1 GPIO1=out:0 GPIO2=in:1 GPIO3=in:0 GPIO4=out:1 GPIO5=out:1 GPIO6=out:0 GPIO7=in:0"},
+	} {
+		pins := tc.raw
+		d := &mockDev{respond: gpioResponder(&pins)}
+		c := &IO{Dev: d}
+
+		a, err := c.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s := a.String(); s != tc.want {
+			t.Fatalf("% x: got %q, want %q", tc.raw, s, tc.want)
+		}
+
+		for i, raw := range tc.raw {
+			pin := Pin(i)
+			if a[pin] != decodePin(raw) || a.Direction(pin) != a[pin].Direction || a.Level(pin) != a[pin].Level {
+				t.Fatalf("% x: pin %d got %+v", tc.raw, i, a[pin])
+			}
+		}
+
+		if n := len(d.written()); n != 1 {
+			t.Fatalf("%d requests, want 1", n)
+		}
+	}
+}
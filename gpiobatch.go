@@ -0,0 +1,72 @@
+package ch347
+
+import (
+	"sync"
+	"time"
+)
+
+// gpioBatch is a set of pin changes sent together by WritePin with GPIOCoalesce.
+type gpioBatch struct {
+	set  [8]byte
+	done chan struct{} // Closed once sent.
+	err  error
+}
+
+type gpioBatcher struct {
+	mu    sync.Mutex
+	batch *gpioBatch // Batch being collected.
+}
+
+// writePinBatched adds pin change to the batch being collected, or starts a new one,
+// and waits for the batch to be sent.
+func (c *IO) writePinBatched(pin Pin, output bool, level bool) error {
+	c.mu.Lock()
+	err := c.checkPinRole(pin, PinRoleGPIO)
+	c.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	set := pinSetByte(output, level)
+
+	bt := &c.gpioBatcher
+	bt.mu.Lock()
+
+	// Batch already changes this pin differently, overwriting it would lose the earlier level.
+	for b := bt.batch; b != nil && b.set[pin] != 0x00 && b.set[pin] != set; b = bt.batch {
+		bt.mu.Unlock()
+		<-b.done
+		bt.mu.Lock()
+	}
+
+	b := bt.batch
+	leader := b == nil
+	if leader {
+		b = &gpioBatch{done: make(chan struct{})}
+		bt.batch = b
+	}
+
+	b.set[pin] = set
+	bt.mu.Unlock()
+
+	if !leader {
+		<-b.done
+		return b.err
+	}
+
+	// Let other writes join.
+	time.Sleep(c.GPIOCoalesce)
+
+	bt.mu.Lock()
+	bt.batch = nil
+	bt.mu.Unlock()
+
+	c.mu.Lock()
+	b.err = c.writePins(b.set)
+	c.mu.Unlock()
+
+	close(b.done)
+
+	return b.err
+}
@@ -0,0 +1,154 @@
+// Package hd44780 drives HD44780 character LCD in 4-bit mode over CH347 GPIO pins.
+//
+// R/W pin of the LCD must be tied to GND, busy flag is not read
+// and delays from the datasheet are used instead.
+//
+// LCD connection as follows:
+//
+//	  CH347       HD44780
+//	- GPIOx   ->  RS
+//	- GPIOx   ->  E
+//	- GPIOx   ->  D4-D7
+//	- GND     ->  R/W
+package hd44780
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/serfreeman1337/go-ch347"
+)
+
+const (
+	cmdClear        = 0x01
+	cmdEntryMode    = 0x06 // Increment cursor, no shift.
+	cmdDisplayOn    = 0x0c // Display on, cursor off, blink off.
+	cmdFunctionSet  = 0x28 // 4-bit bus, 2 lines, 5x8 font.
+	cmdSetDDRAMAddr = 0x80
+)
+
+// Row start addresses in DDRAM.
+var rowOffsets = [4]byte{0x00, 0x40, 0x14, 0x54}
+
+// Display is HD44780 LCD.
+type Display struct {
+	c   *ch347.IO
+	en  ch347.Pin
+	bus *ch347.PinGroup // D4-D7 and RS as bit 4.
+}
+
+// NewGPIO returns display connected to given pins. Call Init before use.
+func NewGPIO(c *ch347.IO, rs, en ch347.Pin, data [4]ch347.Pin) *Display {
+	return &Display{
+		c:   c,
+		en:  en,
+		bus: ch347.NewPinGroup(c, data[0], data[1], data[2], data[3], rs),
+	}
+}
+
+// Init performs initialization by instruction sequence and turns display on.
+func (d *Display) Init() error {
+	// Wait for power up.
+	time.Sleep(50 * time.Millisecond)
+
+	err := d.c.WritePin(d.en, true, false)
+	if err != nil {
+		return err
+	}
+
+	// Display may be in 8-bit or 4-bit mode at this point.
+	// Switch to 8-bit mode first and then to 4-bit mode.
+	for _, delay := range []time.Duration{4100 * time.Microsecond, 100 * time.Microsecond, 100 * time.Microsecond} {
+		err = d.write4(0x03, false)
+		if err != nil {
+			return err
+		}
+
+		time.Sleep(delay)
+	}
+
+	err = d.write4(0x02, false)
+	if err != nil {
+		return err
+	}
+
+	for _, cmd := range []byte{cmdFunctionSet, cmdDisplayOn, cmdClear, cmdEntryMode} {
+		err = d.command(cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Clear clears display and moves cursor home.
+func (d *Display) Clear() error {
+	return d.command(cmdClear)
+}
+
+// SetCursor moves cursor to given position.
+func (d *Display) SetCursor(col, row int) error {
+	if row < 0 || row >= len(rowOffsets) || col < 0 || col > 39 {
+		return fmt.Errorf("invalid cursor position %d:%d", col, row)
+	}
+
+	return d.command(cmdSetDDRAMAddr | (rowOffsets[row] + byte(col)))
+}
+
+// Print prints string at cursor position. Only characters from LCD character ROM are displayed correctly.
+func (d *Display) Print(s string) error {
+	for i := 0; i < len(s); i++ {
+		err := d.send(s[i], true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Display) command(cmd byte) error {
+	err := d.send(cmd, false)
+	if err != nil {
+		return err
+	}
+
+	// Clear and return home instructions take a while.
+	if cmd <= 0x03 {
+		time.Sleep(1520 * time.Microsecond)
+	}
+
+	return nil
+}
+
+// send sends byte as two nibbles, high nibble first.
+func (d *Display) send(b byte, rs bool) error {
+	err := d.write4(b>>4, rs)
+	if err != nil {
+		return err
+	}
+
+	return d.write4(b&0x0f, rs)
+}
+
+// write4 puts nibble and RS on the bus and pulses E.
+func (d *Display) write4(nibble byte, rs bool) error {
+	v := uint(nibble & 0x0f)
+	if rs {
+		v |= 0x10
+	}
+
+	err := d.bus.Write(v)
+	if err != nil {
+		return err
+	}
+
+	// USB round trip is way longer than required 450ns pulse and 37us execution time.
+	err = d.c.WritePin(d.en, true, true)
+	if err != nil {
+		return err
+	}
+
+	return d.c.WritePin(d.en, true, false)
+}
@@ -0,0 +1,94 @@
+package hd44780
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/serfreeman1337/go-ch347"
+)
+
+const (
+	pinRS = ch347.GPIO6
+	pinEN = ch347.GPIO7
+)
+
+// lcdSim is CH347 GPIO interface with HD44780 attached, latching a nibble on every E rising edge.
+type lcdSim struct {
+	pins    [8]byte // Status bytes.
+	resp    []byte
+	nibbles []string // "c3" for command nibble 0x3, "d8" for data nibble 0x8.
+}
+
+func (d *lcdSim) SendFeatureReport(p []byte) (int, error) { return len(p), nil }
+
+func (d *lcdSim) Write(p []byte) (int, error) {
+	en := d.pins[pinEN]
+
+	for i, b := range p[5:13] {
+		switch b {
+		case 0xf8:
+			d.pins[i] = 0xc0
+		case 0xf0:
+			d.pins[i] = 0x80
+		}
+	}
+
+	if en != 0xc0 && d.pins[pinEN] == 0xc0 {
+		var n byte
+		for i, pin := range []ch347.Pin{ch347.GPIO2, ch347.GPIO3, ch347.GPIO4, ch347.GPIO5} {
+			if d.pins[pin] == 0xc0 {
+				n |= 1 << i
+			}
+		}
+
+		kind := "c"
+		if d.pins[pinRS] == 0xc0 {
+			kind = "d"
+		}
+
+		d.nibbles = append(d.nibbles, kind+string("0123456789abcdef"[n]))
+	}
+
+	d.resp = append([]byte{0x0b, 0x00, 0xcc, 0x08, 0x00}, d.pins[:]...)
+
+	return len(p), nil
+}
+
+func (d *lcdSim) Read(p []byte) (int, error) {
+	return copy(p, d.resp), nil
+}
+
+func TestDisplay(t *testing.T) {
+	sim := &lcdSim{}
+	lcd := NewGPIO(&ch347.IO{Dev: sim}, pinRS, pinEN, [4]ch347.Pin{ch347.GPIO2, ch347.GPIO3, ch347.GPIO4, ch347.GPIO5})
+
+	if err := lcd.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 8-bit mode three times, 4-bit mode, then function set, display on, clear and entry mode.
+	want := []string{"c3", "c3", "c3", "c2", "c2", "c8", "c0", "cc", "c0", "c1", "c0", "c6"}
+	if !reflect.DeepEqual(sim.nibbles, want) {
+		t.Fatalf("init: got %v, want %v", sim.nibbles, want)
+	}
+
+	sim.nibbles = nil
+
+	if err := lcd.SetCursor(3, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lcd.Print("Hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	// DDRAM address 0x43, then 'H' and 'i'.
+	want = []string{"cc", "c3", "d4", "d8", "d6", "d9"}
+	if !reflect.DeepEqual(sim.nibbles, want) {
+		t.Fatalf("got %v, want %v", sim.nibbles, want)
+	}
+
+	if err := lcd.SetCursor(40, 0); err == nil {
+		t.Fatal("column 40 accepted")
+	}
+}
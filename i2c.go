@@ -1,10 +1,17 @@
 package ch347
 
-import "errors"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
 
 var (
 	ErrI2CRead  = errors.New("i2c read failed")
 	ErrI2CWrite = errors.New("i2c write failed")
+	ErrI2CBit   = errors.New("register bit out of range")
 )
 
 type I2CMode uint8
@@ -21,17 +28,40 @@ const (
 //   - I2CMode1 - Standart rate 100KHz.
 //   - I2CMode2 - Fast rate 400KHz.
 //   - I2CMode3 - High rate 750KHz.
+//
+// Setting I2C resets SPI configuration, if SPI was configured before,
+// its configuration is re-applied by the next SPI transfer.
 func (c *IO) SetI2C(mode I2CMode) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	p := []byte{0x03, 0x00, 0xaa, 0x60 | byte(mode), 0x00}
-	_, err := c.Dev.Write(p)
-	return err
+	err := c.write(p)
+	if err != nil {
+		return err
+	}
+
+	c.i2cSet, c.i2cMode = true, mode
+	c.spiStale = c.spiSet
+
+	return nil
+}
+
+// GetI2C returns I2C mode set by SetI2C. ok is false if I2C was not configured yet.
+func (c *IO) GetI2C() (mode I2CMode, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.i2cMode, c.i2cSet
 }
 
 // I2C performs write and read operations with device on given address.
 //
+// With nil w it performs a plain read: START, address with read bit, len(r) bytes, STOP.
+// With nil r it performs a plain write. Otherwise, w is written and r is read after a repeated START.
+//
+// There is no limit on w and r lengths, transfers are split into as many packets as needed.
+//
 // Example:
 //
 //	// Read all 4096 bytes from 24C32B chip
@@ -49,6 +79,21 @@ func (c *IO) I2C(addr uint16, w, r []byte) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.activity()
+
+	err := c.i2c(addr, w, r)
+	c.metrics.done(&c.metrics.i2cBytes, len(w)+len(r), err)
+
+	return err
+}
+
+func (c *IO) i2c(addr uint16, w, r []byte) error {
+	return c.i2cAcks(addr, w, r, nil)
+}
+
+// i2cAcks performs I2C transfer. With non-nil acks, confirmations of written bytes,
+// address included, are appended to it instead of failing on NACK.
+func (c *IO) i2cAcks(addr uint16, w, r []byte, acks *[]bool) error {
 	const (
 		// The command package of the I2C interface, starting from the secondary byte, is the I2C command stream
 		CmdI2CStream = 0xAA
@@ -66,7 +111,11 @@ func (c *IO) I2C(addr uint16, w, r []byte) error {
 		CmdI2CRead = 0xc0 // Note: a reads must be completed with one byte reading (0xc0), otherwise next operation will fail.
 	)
 
-	const maxLen = 63 // Max data length with 6 bits.
+	maxLen := min(63, c.packetSize()-8) // Max data length with 6 bits, fitting a packet with headers.
+
+	if err := c.checkI2CPins(); err != nil {
+		return err
+	}
 
 	p := make([]byte, 0, 512)
 
@@ -84,7 +133,7 @@ func (c *IO) I2C(addr uint16, w, r []byte) error {
 		p[0] = byte(plen & 0xff)
 		p[1] = byte((plen >> 8) & 0xff)
 
-		_, err := c.Dev.Write(p)
+		err := c.write(p)
 		if err != nil {
 			return err
 		}
@@ -98,7 +147,7 @@ func (c *IO) I2C(addr uint16, w, r []byte) error {
 			rlen := (2 + clen)
 			p = p[:rlen]
 
-			_, err = c.Dev.Read(p)
+			_, err = c.read(p)
 			if err != nil {
 				return err
 			}
@@ -106,18 +155,13 @@ func (c *IO) I2C(addr uint16, w, r []byte) error {
 			pos := 2 // Skip 2 bytes in begining.
 
 			// Confirm writes.
-			for toWrite > 0 {
-				if p[pos] == 0x00 {
-					// pos += toWrite
-					// toWrite = 0
-					// break
-					return ErrI2CWrite
-				}
-
-				toWrite--
-				pos++
+			if err = confirmI2CWrites(p[pos:pos+toWrite], acks); err != nil {
+				return err
 			}
 
+			pos += toWrite
+			toWrite = 0
+
 			// Confirm reads.
 			if toRead > 0 {
 				if hasRead { // Confirm read request.
@@ -144,7 +188,7 @@ func (c *IO) I2C(addr uint16, w, r []byte) error {
 
 	pack := func(elems ...byte) error {
 		// First make it work, then make it better.
-		if (len(p) + len(elems)) >= (maxPacketLen - 2) {
+		if (len(p) + len(elems)) >= (c.packetSize() - 2) {
 			if err := write(); err != nil {
 				return err
 			}
@@ -227,8 +271,8 @@ func (c *IO) I2C(addr uint16, w, r []byte) error {
 				dlen = maxLen
 			}
 
-			if nlen := (2 + toWrite + toRead + dlen); nlen >= maxPacketLen {
-				dlen -= (nlen - maxPacketLen)
+			if nlen := (2 + toWrite + toRead + dlen); nlen >= c.packetSize() {
+				dlen -= (nlen - c.packetSize())
 				send = true
 
 				if hasRead {
@@ -274,22 +318,397 @@ func (c *IO) I2C(addr uint16, w, r []byte) error {
 			toRead++
 		}
 
-		d = append(d, CmdI2CRead)
+		// Last byte is NACKed as the spec requires, unless asked otherwise.
+		if c.I2CAckLastRead {
+			d = append(d, CmdI2CRead|1)
+		} else {
+			d = append(d, CmdI2CRead)
+		}
+
 		err := pack(d...)
 		if err != nil {
 			return err
 		}
 	}
 
-	err := pack(CmdI2CStop)
+	if !c.I2CNoStop {
+		err := pack(CmdI2CStop)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(p) == 0 { // Nothing left to send.
+		return nil
+	}
+
+	err := write()
 	if err != nil {
 		return err
 	}
 
-	err = write()
+	return nil
+}
+
+// I2CStop generates STOP, releasing the bus held with I2CNoStop.
+func (c *IO) I2CStop() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkI2CPins(); err != nil {
+		return err
+	}
+
+	// len		CMD	STOP	END
+	// 03 00	aa	75		00
+	return c.write([]byte{0x03, 0x00, 0xaa, 0x75, 0x00})
+}
+
+// I2CWriteFrom streams n bytes from src to device on given address, without buffering them all.
+//
+// Data is written in chunks, each one prefixed with regPrefix, which is treated as big-endian
+// register (memory) address and is advanced by the chunk length. With I2CPageSize set, chunks
+// are aligned to EEPROM pages, and write cycle completion is awaited by polling for ACK.
+// Every chunk ends with STOP, which starts EEPROM write cycle, even with I2CNoStop set.
+// regPrefix is up to 8 bytes long.
+// Note: write cycle of the last chunk might still be in progress on return.
+//
+// Example:
+//
+//	// Write firmware image to 24C32 EEPROM starting from address 0x0000.
+//	f, _ := os.Open("image.bin")
+//	st, _ := f.Stat()
+//
+//	c.I2CPageSize = 32
+//	err = c.I2CWriteFrom(0x50, []byte{0x00, 0x00}, f, int(st.Size()))
+func (c *IO) I2CWriteFrom(addr uint16, regPrefix []byte, src io.Reader, n int) error {
+	if len(regPrefix) > 8 {
+		return fmt.Errorf("register prefix is %d bytes long, 8 max", len(regPrefix))
+	}
+
+	var reg uint64
+	for _, b := range regPrefix {
+		reg = reg<<8 | uint64(b)
+	}
+
+	pageSize := c.I2CPageSize
+	if pageSize <= 0 {
+		pageSize = 256
+	}
+
+	w := make([]byte, len(regPrefix)+pageSize)
+
+	for n > 0 {
+		dlen := pageSize
+		if c.I2CPageSize > 0 {
+			dlen -= int(reg % uint64(pageSize)) // Up to the page end.
+		}
+
+		if dlen > n {
+			dlen = n
+		}
+
+		for i := range regPrefix {
+			w[i] = byte(reg >> (8 * (len(regPrefix) - 1 - i)))
+		}
+
+		chunk := w[:len(regPrefix)+dlen]
+		_, err := io.ReadFull(src, chunk[len(regPrefix):])
+		if err != nil {
+			return err
+		}
+
+		err = c.i2cWritePolled(addr, chunk)
+		if err != nil {
+			return err
+		}
+
+		reg += uint64(dlen)
+		n -= dlen
+	}
+
+	return nil
+}
+
+// i2cWritePolled writes w ending with STOP, retrying while the device NACKs it
+// being busy with EEPROM write cycle.
+func (c *IO) i2cWritePolled(addr uint16, w []byte) error {
+	const writeCycle = 10 * time.Millisecond // Max write cycle time of common EEPROMs.
+
+	deadline := time.Now().Add(writeCycle)
+
+	for {
+		err := c.i2cWriteStop(addr, w)
+		if err != ErrI2CWrite || c.I2CPageSize <= 0 || time.Now().After(deadline) {
+			return err
+		}
+	}
+}
+
+// i2cWriteStop is like I2C write, but always ends with STOP.
+func (c *IO) i2cWriteStop(addr uint16, w []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.activity()
+
+	noStop := c.I2CNoStop
+	c.I2CNoStop = false
+
+	err := c.i2c(addr, w, nil)
+	c.I2CNoStop = noStop
+
+	c.metrics.done(&c.metrics.i2cBytes, len(w), err)
+
+	return err
+}
+
+// I2CWriteVerbose writes w to device on given address and returns ACK status of every byte
+// written: first for the address byte, then one for each byte of w. NACKs are not errors here.
+//
+// It's meant for debugging devices NACKing in the middle of a write.
+//
+// Example:
+//
+//	acks, err := c.I2CWriteVerbose(0x50, []byte{0x00, 0x10, 0xaa})
+//	fmt.Println(acks) // [true true true false] - data byte was NACKed.
+func (c *IO) I2CWriteVerbose(addr uint16, w []byte) ([]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.activity()
+
+	acks := make([]bool, 0, len(w)+1)
+
+	err := c.i2cAcks(addr, w, nil, &acks)
+	c.metrics.done(&c.metrics.i2cBytes, len(w), err)
+
+	return acks, err
+}
+
+// ReadReg writes register pointer reg and reads len(r) bytes into r after a repeated START,
+// in a single transaction with one STOP at the end, unless I2CNoStop is set.
+//
+// Unlike writing reg with one I2C call and reading with another, there is no STOP in between,
+// so the bus is not released and devices resetting their register pointer on STOP read the right register.
+// With I2CNoStop the bus is left held like with I2C, call I2CStop to release it.
+//
+// Example:
+//
+//	// Read 2 bytes of BMP280 calibration data.
+//	r := make([]byte, 2)
+//	err := c.ReadReg(0x76, []byte{0x88}, r)
+func (c *IO) ReadReg(addr uint16, reg []byte, r []byte) error {
+	return c.I2C(addr, reg, r)
+}
+
+// I2CCRC performs I2C transfer like I2C and verifies trailing CRC of r.
+//
+// Last crcLen bytes of r are the CRC, crcFn must return CRC of the given data as it's sent by the device.
+// ErrCRC is returned on CRC mismatch, with r holding the data received.
+//
+// AHT2X appends CRC-8 (see CRC8Trailer) to its measurements. Sensirion SHT3x/SHT4x and SCD4x
+// use the same CRC-8, but after every 2 bytes word, verify those with CRC8 per word instead.
+//
+// Example:
+//
+//	// Read AHT2X measurements with CRC.
+//	r := make([]byte, 7)
+//	err := c.I2CCRC(0x38, nil, r, 1, ch347.CRC8Trailer)
+func (c *IO) I2CCRC(addr uint16, w, r []byte, crcLen int, crcFn func([]byte) []byte) error {
+	if crcLen <= 0 || len(r) < crcLen {
+		return fmt.Errorf("read length %d doesn't fit %d bytes crc", len(r), crcLen)
+	}
+
+	err := c.I2C(addr, w, r)
 	if err != nil {
 		return err
 	}
 
+	if !bytes.Equal(crcFn(r[:len(r)-crcLen]), r[len(r)-crcLen:]) {
+		return ErrCRC
+	}
+
+	return nil
+}
+
+// ScanI2C returns addresses in 0x08-0x77 range which ACK their address with write bit.
+// Nothing is written to devices besides the address.
+//
+// Example:
+//
+//	addrs, err := c.ScanI2C()
+//	for _, addr := range addrs {
+//		fmt.Printf("found device at 0x%02x\n", addr)
+//	}
+func (c *IO) ScanI2C() ([]uint16, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var found []uint16
+
+	for addr := uint16(0x08); addr <= 0x77; addr++ {
+		ack, err := c.probeI2C(addr)
+		if err != nil {
+			return found, err
+		}
+
+		if ack {
+			found = append(found, addr)
+		}
+	}
+
+	return found, nil
+}
+
+// probeI2C sends START, address with write bit and STOP, reporting whether the address was ACKed.
+func (c *IO) probeI2C(addr uint16) (bool, error) {
+	//	len		CMD	START	WRITE 1	ADDR		STOP	END
+	//	06 00	aa	74		81		addr << 1	75		00
+	p := []byte{0x06, 0x00, 0xaa, 0x74, 0x81, byte(addr << 1), 0x75, 0x00}
+
+	err := c.checkI2CPins()
+	if err != nil {
+		return false, err
+	}
+
+	err = c.write(p)
+	if err != nil {
+		return false, err
+	}
+
+	// Confirmation of a single written byte.
+	p = p[:3]
+	_, err = c.read(p)
+	if err != nil {
+		return false, err
+	}
+
+	acks := make([]bool, 0, 1)
+	err = confirmI2CWrites(p[2:], &acks)
+
+	return acks[0], err
+}
+
+// confirmI2CWrites checks confirmations of written bytes, 0x00 is NACK.
+// With non-nil acks, they are appended to it instead of failing with ErrI2CWrite on NACK.
+func confirmI2CWrites(conf []byte, acks *[]bool) error {
+	for _, b := range conf {
+		if acks != nil {
+			*acks = append(*acks, b != 0x00)
+		} else if b == 0x00 {
+			return ErrI2CWrite
+		}
+	}
+
 	return nil
 }
+
+// ReadFIFO reads n bytes from the FIFO register of device on given address.
+//
+// Register address is written once, followed by a repeated start and a single read
+// of all n bytes, which is way faster than reading FIFO byte by byte. n must be positive.
+//
+// Example:
+//
+//	// Read 12 bytes of accel and gyro samples from MPU-6050 FIFO_R_W register.
+//	r, err := c.ReadFIFO(0x68, 0x74, 12)
+func (c *IO) ReadFIFO(addr uint16, fifoReg uint8, n int) ([]byte, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid fifo read length %d", n)
+	}
+
+	r := make([]byte, n)
+
+	err := c.I2C(addr, []byte{fifoReg}, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// BenchmarkI2C reads size bytes from device on given address and returns achieved rate in bytes per second.
+//
+// Note: it performs real reads, make sure reading from the device has no side effects.
+func (c *IO) BenchmarkI2C(addr uint16, size int) (rate float64, err error) {
+	r := make([]byte, size)
+
+	start := time.Now()
+	err = c.I2C(addr, nil, r)
+	took := time.Since(start)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(size) / took.Seconds(), nil
+}
+
+// UpdateI2CBits performs read-modify-write of the register reg of device on given address.
+// Only bits set in mask are changed to the ones in val.
+//
+// The lock is held for both the read and the write, so concurrent updates of the same register
+// don't overwrite each other.
+func (c *IO) UpdateI2CBits(addr uint16, reg uint8, mask, val uint8) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.updateI2CBits(addr, reg, mask, val)
+}
+
+func (c *IO) updateI2CBits(addr uint16, reg uint8, mask, val uint8) error {
+	c.activity()
+
+	r := make([]byte, 1)
+
+	err := c.i2c(addr, []byte{reg}, r)
+	c.metrics.done(&c.metrics.i2cBytes, 2, err)
+	if err != nil {
+		return err
+	}
+
+	v := (r[0] &^ mask) | (val & mask)
+	if v == r[0] {
+		return nil
+	}
+
+	err = c.i2c(addr, []byte{reg, v}, nil)
+	c.metrics.done(&c.metrics.i2cBytes, 2, err)
+
+	return err
+}
+
+// SetI2CBit sets or clears a single bit of the register reg of device on given address.
+// Other bits are preserved. ErrI2CBit is returned for bit above 7.
+func (c *IO) SetI2CBit(addr uint16, reg uint8, bit uint, on bool) error {
+	if bit > 7 {
+		return ErrI2CBit
+	}
+
+	mask := uint8(1) << bit
+
+	var val uint8
+	if on {
+		val = mask
+	}
+
+	return c.UpdateI2CBits(addr, reg, mask, val)
+}
+
+// GetI2CBit returns a single bit of the register reg of device on given address.
+// ErrI2CBit is returned for bit above 7.
+func (c *IO) GetI2CBit(addr uint16, reg uint8, bit uint) (bool, error) {
+	if bit > 7 {
+		return false, ErrI2CBit
+	}
+
+	r := make([]byte, 1)
+
+	err := c.I2C(addr, []byte{reg}, r)
+	if err != nil {
+		return false, err
+	}
+
+	return r[0]&(1<<bit) != 0, nil
+}
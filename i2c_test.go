@@ -0,0 +1,421 @@
+package ch347
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// i2cSim models I2C command stream (0xaa) of the chip: writes are ACKed, reads return 0x00, 0x01, ...
+// counting across the whole transfer. Reads fail unless they ask for the exact response length.
+type i2cSim struct {
+	t      *testing.T
+	resps  [][]byte
+	next   byte
+	reads  int      // Data bytes read.
+	starts int      // START conditions, repeated ones included.
+	stops  int      // STOP conditions.
+	maxLen int      // Longest packet written or response.
+	writes [][]byte // Data of every write command, address included.
+}
+
+func (d *i2cSim) SendFeatureReport(p []byte) (int, error) { return len(p), nil }
+
+func (d *i2cSim) Write(p []byte) (int, error) {
+	if len(p) > maxPacketLen {
+		d.t.Fatalf("packet is %d bytes long", len(p))
+	}
+
+	d.maxLen = max(d.maxLen, len(p))
+
+	if plen := int(p[0]) | int(p[1])<<8; plen != len(p)-2 || p[2] != 0xaa {
+		d.t.Fatalf("bad packet header % x", p[:3])
+	}
+
+	var resp []byte
+	for i := 3; i < len(p); {
+		cmd := p[i]
+		i++
+
+		switch {
+		case cmd == 0x74: // START.
+			d.starts++
+		case cmd == 0x75: // STOP.
+			d.stops++
+		case cmd == 0x00: // End of packet.
+			if i != len(p) {
+				d.t.Fatalf("0x00 in the middle of packet")
+			}
+		case cmd&0xc0 == 0x80: // Write, ACK every byte.
+			n := int(cmd & 0x3f)
+			d.writes = append(d.writes, append([]byte(nil), p[i:i+n]...))
+			for k := 0; k < n; k++ {
+				resp = append(resp, 0x01)
+			}
+			i += n
+		case cmd&0xc0 == 0xc0: // Read, 0 length reads a single byte.
+			n := max(int(cmd&0x3f), 1)
+			for k := 0; k < n; k++ {
+				resp = append(resp, d.next)
+				d.next++
+				d.reads++
+			}
+		default:
+			d.t.Fatalf("unknown command %#x", cmd)
+		}
+	}
+
+	if len(resp) > 0 {
+		if len(resp)+2 > maxPacketLen {
+			d.t.Fatalf("response is %d bytes long", len(resp)+2)
+		}
+
+		d.resps = append(d.resps, append([]byte{byte(len(resp)), byte(len(resp) >> 8)}, resp...))
+		d.maxLen = max(d.maxLen, len(resp)+2)
+	}
+
+	return len(p), nil
+}
+
+func (d *i2cSim) Read(p []byte) (int, error) {
+	if len(d.resps) == 0 {
+		return 0, fmt.Errorf("no response")
+	}
+
+	r := d.resps[0]
+	d.resps = d.resps[1:]
+
+	if len(p) != len(r) {
+		return 0, fmt.Errorf("read %d bytes, response is %d bytes", len(p), len(r))
+	}
+
+	return copy(p, r), nil
+}
+
+func TestI2CRead(t *testing.T) {
+	for _, wlen := range []int{-1, 1, 2, 62, 63, 64, 200, 505, 506, 507, 508, 509, 510, 511, 1000} {
+		var w []byte
+		if wlen >= 0 {
+			w = make([]byte, wlen)
+		}
+
+		for n := 1; n < 1600; n++ {
+			d := &i2cSim{t: t}
+			c := &IO{Dev: d}
+
+			r := make([]byte, n)
+			if err := c.I2C(0x50, w, r); err != nil {
+				t.Fatalf("w %d, r %d: %v", wlen, n, err)
+			}
+
+			if d.reads != n || len(d.resps) != 0 {
+				t.Fatalf("w %d, r %d: %d bytes read, %d responses left", wlen, n, d.reads, len(d.resps))
+			}
+
+			for i := range r {
+				if r[i] != byte(i) {
+					t.Fatalf("w %d, r %d: r[%d] = %d", wlen, n, i, r[i])
+				}
+			}
+		}
+	}
+}
+
+func TestI2CLargeTransfer(t *testing.T) {
+	for _, n := range []int{4096, 65536} {
+		d := &i2cSim{t: t}
+		c := &IO{Dev: d}
+
+		r := make([]byte, n)
+		if err := c.I2C(0x50, make([]byte, n), r); err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+
+		if d.reads != n {
+			t.Fatalf("%d bytes: %d bytes read", n, d.reads)
+		}
+
+		for i := range r {
+			if r[i] != byte(i) {
+				t.Fatalf("%d bytes: r[%d] = %d", n, i, r[i])
+			}
+		}
+	}
+}
+
+func TestUpdateI2CBits(t *testing.T) {
+	d := &i2cSim{t: t, next: 0xa0}
+	c := &IO{Dev: d}
+
+	if err := c.UpdateI2CBits(0x50, 0x10, 0x0f, 0x05); err != nil {
+		t.Fatal(err)
+	}
+
+	// Register read, then 0xa0 updated to 0xa5.
+	want := [][]byte{{0xa0, 0x10}, {0xa1}, {0xa0, 0x10, 0xa5}}
+	if !reflect.DeepEqual(d.writes, want) {
+		t.Fatalf("got % x, want % x", d.writes, want)
+	}
+
+	if err := c.SetI2CBit(0x50, 0x10, 8, true); err != ErrI2CBit {
+		t.Fatalf("SetI2CBit: got %v, want ErrI2CBit", err)
+	}
+
+	if _, err := c.GetI2CBit(0x50, 0x10, 8); err != ErrI2CBit {
+		t.Fatalf("GetI2CBit: got %v, want ErrI2CBit", err)
+	}
+}
+
+func TestI2CCRC(t *testing.T) {
+	// Simulated device reads 0x00, 0x01, 0x02, so 0x02 is a wrong trailer of 0x00, 0x01.
+	c := &IO{Dev: &i2cSim{t: t}}
+	if err := c.I2CCRC(0x38, nil, make([]byte, 3), 1, CRC8Trailer); err != ErrCRC {
+		t.Fatalf("got %v, want ErrCRC", err)
+	}
+
+	// CRC matching what's read.
+	c = &IO{Dev: &i2cSim{t: t}}
+	crcFn := func(p []byte) []byte { return []byte{byte(len(p))} }
+	if err := c.I2CCRC(0x38, nil, make([]byte, 3), 1, crcFn); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.I2CCRC(0x38, nil, make([]byte, 1), 2, crcFn); err == nil {
+		t.Fatal("read shorter than crc accepted")
+	}
+}
+
+func TestScanI2C(t *testing.T) {
+	d := &mockDev{respond: func(p []byte) [][]byte {
+		ack := byte(0x00)
+		if addr := p[5] >> 1; addr == 0x3c || addr == 0x50 {
+			ack = 0x01
+		}
+
+		return [][]byte{{1, 0, ack}}
+	}}
+	c := &IO{Dev: d}
+
+	found, err := c.ScanI2C()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []uint16{0x3c, 0x50}; !reflect.DeepEqual(found, want) {
+		t.Fatalf("got %#x, want %#x", found, want)
+	}
+}
+
+func TestI2CWriteNACK(t *testing.T) {
+	d := &mockDev{respond: func(p []byte) [][]byte {
+		return [][]byte{{3, 0, 0x01, 0x01, 0x00}} // Last byte NACKed.
+	}}
+	c := &IO{Dev: d}
+
+	if err := c.I2C(0x50, []byte{0x00, 0x01}, nil); err != ErrI2CWrite {
+		t.Fatalf("got %v, want ErrI2CWrite", err)
+	}
+
+	acks, err := c.I2CWriteVerbose(0x50, []byte{0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []bool{true, true, false}; !reflect.DeepEqual(acks, want) {
+		t.Fatalf("got %v, want %v", acks, want)
+	}
+}
+
+func TestReadFIFO(t *testing.T) {
+	d := &i2cSim{t: t}
+	c := &IO{Dev: d}
+
+	r, err := c.ReadFIFO(0x68, 0x74, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Register address is written once, then all bytes are read after a repeated start.
+	if len(d.writes) != 2 || !bytes.Equal(d.writes[0], []byte{0x68 << 1, 0x74}) || !bytes.Equal(d.writes[1], []byte{0x68<<1 | 1}) {
+		t.Fatalf("writes % x", d.writes)
+	}
+
+	if d.reads != 12 || len(r) != 12 || r[11] != 11 {
+		t.Fatalf("read %d bytes: % x", d.reads, r)
+	}
+
+	for _, n := range []int{0, -1} {
+		if _, err := c.ReadFIFO(0x68, 0x74, n); err == nil {
+			t.Fatalf("n %d: no error", n)
+		}
+	}
+}
+
+func TestI2CWriteFrom(t *testing.T) {
+	d := &i2cSim{t: t}
+	c := &IO{Dev: d, I2CPageSize: 32, I2CNoStop: true}
+
+	src := make([]byte, 70)
+	for i := range src {
+		src[i] = byte(i)
+	}
+
+	if err := c.I2CWriteFrom(0x50, []byte{0x00, 0x10}, bytes.NewReader(src), len(src)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Chunks end at page boundaries, each one with its own address and STOP.
+	var got []byte
+	for i, want := range []struct{ reg, n int }{{0x10, 16}, {0x20, 32}, {0x40, 22}} {
+		w := d.writes[i]
+		if w[0] != 0x50<<1 || int(w[1])<<8|int(w[2]) != want.reg || len(w) != 3+want.n {
+			t.Fatalf("chunk %d: % x", i, w)
+		}
+
+		got = append(got, w[3:]...)
+	}
+
+	if len(d.writes) != 3 || d.stops != 3 || !bytes.Equal(got, src) {
+		t.Fatalf("%d writes, %d stops, data % x", len(d.writes), d.stops, got)
+	}
+
+	if !c.I2CNoStop {
+		t.Fatal("I2CNoStop not restored")
+	}
+
+	if err := c.I2CWriteFrom(0x50, make([]byte, 9), bytes.NewReader(src), 1); err == nil {
+		t.Fatal("9 bytes register prefix accepted")
+	}
+}
+
+// slowI2C is i2cSim taking delay to respond.
+type slowI2C struct {
+	i2cSim
+	delay time.Duration
+}
+
+func (d *slowI2C) Read(p []byte) (int, error) {
+	time.Sleep(d.delay)
+	return d.i2cSim.Read(p)
+}
+
+func TestBenchmarkI2C(t *testing.T) {
+	d := &slowI2C{i2cSim: i2cSim{t: t}, delay: 20 * time.Millisecond}
+	c := &IO{Dev: d}
+
+	// 200 bytes take a single response.
+	rate, err := c.BenchmarkI2C(0x50, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rate > 200/0.02 || rate < 200/0.2 || d.reads != 200 {
+		t.Fatalf("%.0f bytes/s for %d bytes read", rate, d.reads)
+	}
+}
+
+// i2cPackets is i2cSim keeping raw packets written.
+type i2cPackets struct {
+	i2cSim
+	packets [][]byte
+}
+
+func (d *i2cPackets) Write(p []byte) (int, error) {
+	d.packets = append(d.packets, append([]byte(nil), p...))
+	return d.i2cSim.Write(p)
+}
+
+func TestI2CAckLastRead(t *testing.T) {
+	for _, tc := range []struct {
+		ack  bool
+		want byte
+	}{
+		{false, 0xc0}, // NACKed single byte read.
+		{true, 0xc1},
+	} {
+		d := &i2cPackets{i2cSim: i2cSim{t: t}}
+		c := &IO{Dev: d, I2CAckLastRead: tc.ack}
+
+		r := make([]byte, 4)
+		if err := c.I2C(0x50, []byte{0x10}, r); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(r, []byte{0, 1, 2, 3}) {
+			t.Fatalf("ack %v: got % x", tc.ack, r)
+		}
+
+		// Final read command right before STOP.
+		p := d.packets[len(d.packets)-1]
+		i := bytes.LastIndexByte(p, 0x75)
+		if i < 1 || p[i-1] != tc.want {
+			t.Fatalf("ack %v: got % x, want 0x%02x before STOP", tc.ack, p, tc.want)
+		}
+	}
+}
+
+func TestI2CNoStop(t *testing.T) {
+	d := &i2cPackets{i2cSim: i2cSim{t: t}}
+	c := &IO{Dev: d, I2CNoStop: true}
+
+	// Write, then continue with a read in the next call.
+	if err := c.I2C(0x50, []byte{0x10}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.I2C(0x50, nil, make([]byte, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range d.packets {
+		if bytes.IndexByte(p[3:], 0x75) >= 0 {
+			t.Fatalf("STOP in % x", p)
+		}
+	}
+
+	if d.stops != 0 || d.reads != 2 {
+		t.Fatalf("%d stops, %d bytes read", d.stops, d.reads)
+	}
+
+	// Bus is released explicitly.
+	if err := c.I2CStop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.stops != 1 {
+		t.Fatalf("%d stops after I2CStop", d.stops)
+	}
+
+	c.I2CNoStop = false
+	if err := c.I2C(0x50, []byte{0x10}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.stops != 2 {
+		t.Fatalf("%d stops without I2CNoStop", d.stops)
+	}
+}
+
+func TestReadReg(t *testing.T) {
+	for _, n := range []int{2, 600} {
+		d := &i2cPackets{i2cSim: i2cSim{t: t}}
+		c := &IO{Dev: d}
+
+		r := make([]byte, n)
+		if err := c.ReadReg(0x76, []byte{0x88}, r); err != nil {
+			t.Fatal(err)
+		}
+
+		// START, register write, repeated START, read, single STOP at the very end.
+		if d.starts != 2 || d.stops != 1 || d.reads != n || !bytes.Equal(d.writes[0], []byte{0x76 << 1, 0x88}) {
+			t.Fatalf("%d bytes: %d starts, %d stops, %d bytes read, writes % x", n, d.starts, d.stops, d.reads, d.writes)
+		}
+
+		last := d.packets[len(d.packets)-1]
+		if cmds := bytes.TrimRight(last, "\x00"); cmds[len(cmds)-1] != 0x75 {
+			t.Fatalf("%d bytes: STOP is not the last command: % x", n, last)
+		}
+	}
+}
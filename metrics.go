@@ -0,0 +1,64 @@
+package ch347
+
+import "sync/atomic"
+
+// IOMetrics is a snapshot of IO operation counters.
+type IOMetrics struct {
+	SPIBytes uint64 // Bytes written and read over SPI.
+	I2CBytes uint64 // Bytes written and read over I2C.
+	GPIOOps  uint64 // GPIO reads and writes.
+	Errors   uint64 // Failed operations.
+}
+
+// UARTMetrics is a snapshot of UART operation counters.
+type UARTMetrics struct {
+	TxBytes uint64
+	RxBytes uint64
+	Errors  uint64 // Failed reads and writes.
+}
+
+type ioMetrics struct {
+	spiBytes, i2cBytes, gpioOps, errors atomic.Uint64
+}
+
+type uartMetrics struct {
+	txBytes, rxBytes, errors atomic.Uint64
+}
+
+// done adds n to counter or counts an error.
+func (m *ioMetrics) done(counter *atomic.Uint64, n int, err error) {
+	if err != nil {
+		m.errors.Add(1)
+		return
+	}
+
+	counter.Add(uint64(n))
+}
+
+func (m *uartMetrics) done(counter *atomic.Uint64, n int, err error) {
+	if err != nil {
+		m.errors.Add(1)
+		return
+	}
+
+	counter.Add(uint64(n))
+}
+
+// Metrics returns operation counters. It's safe to call concurrently with operations.
+func (c *IO) Metrics() IOMetrics {
+	return IOMetrics{
+		SPIBytes: c.metrics.spiBytes.Load(),
+		I2CBytes: c.metrics.i2cBytes.Load(),
+		GPIOOps:  c.metrics.gpioOps.Load(),
+		Errors:   c.metrics.errors.Load(),
+	}
+}
+
+// Metrics returns operation counters. It's safe to call concurrently with operations.
+func (c *UART) Metrics() UARTMetrics {
+	return UARTMetrics{
+		TxBytes: c.metrics.txBytes.Load(),
+		RxBytes: c.metrics.rxBytes.Load(),
+		Errors:  c.metrics.errors.Load(),
+	}
+}
@@ -0,0 +1,80 @@
+package ch347
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIOMetrics(t *testing.T) {
+	var pins [8]byte
+	gpio := gpioResponder(&pins)
+
+	d := &mockDev{respond: func(p []byte) [][]byte {
+		if p[2] == 0xcc {
+			return gpio(p)
+		}
+
+		return spiResponder(p)
+	}}
+	c := &IO{Dev: d}
+
+	if err := c.SetSPI(SPIMode0, SPIClock0, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SPI(make([]byte, 10), make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.WritePin(GPIO1, true, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.ReadPin(GPIO1); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Dev = &i2cSim{t: t}
+	if err := c.I2C(0x50, make([]byte, 2), make([]byte, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed operations count as errors only.
+	c.Dev = &mockDev{writeErr: errors.New("fail")}
+	if err := c.I2C(0x50, make([]byte, 2), nil); err == nil {
+		t.Fatal("no error")
+	}
+
+	if err := c.WritePin(GPIO1, true, false); err == nil {
+		t.Fatal("no error")
+	}
+
+	want := IOMetrics{SPIBytes: 15, I2CBytes: 5, GPIOOps: 2, Errors: 2}
+	if m := c.Metrics(); m != want {
+		t.Fatalf("got %+v, want %+v", m, want)
+	}
+}
+
+func TestUARTMetrics(t *testing.T) {
+	d := &mockDev{}
+	c := &UART{Dev: d}
+
+	if _, err := c.Write(make([]byte, 600)); err != nil {
+		t.Fatal(err)
+	}
+
+	d.queue([]byte{3, 0, 'a', 'b', 'c'})
+	if _, err := c.Read(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	d.readErr = errors.New("fail")
+	if _, err := c.Read(make([]byte, 10)); err == nil {
+		t.Fatal("no error")
+	}
+
+	want := UARTMetrics{TxBytes: 600, RxBytes: 3, Errors: 1}
+	if m := c.Metrics(); m != want {
+		t.Fatalf("got %+v, want %+v", m, want)
+	}
+}
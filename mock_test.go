@@ -0,0 +1,176 @@
+package ch347
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errHIDTimeout mimics go-hid ErrTimeout, a plain error with "timeout" message.
+var errHIDTimeout = errors.New("timeout")
+
+// mockDev is HIDDev recording written packets and returning queued responses.
+type mockDev struct {
+	mu sync.Mutex
+
+	// respond, if set, returns responses to a written packet.
+	respond func(p []byte) [][]byte
+
+	resps    [][]byte
+	writes   [][]byte
+	features [][]byte
+
+	readErr  error // Returned once responses run out, defaults to errHIDTimeout.
+	writeErr error
+	closed   bool
+}
+
+func (d *mockDev) Read(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.resps) == 0 {
+		if d.readErr != nil {
+			return -1, d.readErr
+		}
+
+		return 0, errHIDTimeout
+	}
+
+	r := d.resps[0]
+	d.resps = d.resps[1:]
+
+	return copy(p, r), nil
+}
+
+func (d *mockDev) ReadWithTimeout(p []byte, _ time.Duration) (int, error) {
+	return d.Read(p)
+}
+
+func (d *mockDev) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.writeErr != nil {
+		return -1, d.writeErr
+	}
+
+	d.writes = append(d.writes, append([]byte(nil), p...))
+	if d.respond != nil {
+		d.resps = append(d.resps, d.respond(p)...)
+	}
+
+	return len(p), nil
+}
+
+func (d *mockDev) SendFeatureReport(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.features = append(d.features, append([]byte(nil), p...))
+
+	return len(p), nil
+}
+
+func (d *mockDev) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+
+	return nil
+}
+
+// queue adds responses to be read.
+func (d *mockDev) queue(resps ...[]byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.resps = append(d.resps, resps...)
+}
+
+// written returns a copy of packets written so far.
+func (d *mockDev) written() [][]byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([][]byte(nil), d.writes...)
+}
+
+// spiResponder answers SPI config (0xc0), write (0xc4), read (0xc3) and duplex (0xc2) packets.
+// Read data is 0x00, 0x01, ... counting across the whole read, duplex echoes MOSI.
+func spiResponder(p []byte) [][]byte {
+	switch p[2] {
+	case 0xc0:
+		return [][]byte{{4, 0, 0xc0, 1, 0, 0}}
+	case 0xc4:
+		return [][]byte{{3, 0, 0xc4, 1, 0}}
+	case 0xc3:
+		rlen := int(p[5]) | int(p[6])<<8 | int(p[7])<<16 | int(p[8])<<24
+
+		var resps [][]byte
+		for pos := 0; pos < rlen; {
+			dlen := min(rlen-pos, 507)
+
+			resp := []byte{byte(dlen + 3), byte((dlen + 3) >> 8), 0xc3, byte(dlen), byte(dlen >> 8)}
+			for i := 0; i < dlen; i++ {
+				resp = append(resp, byte(pos+i))
+			}
+
+			resps = append(resps, resp)
+			pos += dlen
+		}
+
+		return resps
+	case 0xc2:
+		return [][]byte{append([]byte(nil), p...)}
+	}
+
+	return nil
+}
+
+// csOps returns CS packets states written, e.g. "0+" for CS0 asserted and "1-" for CS1 deasserted,
+// along with "spi" for any other SPI packet.
+func csOps(writes [][]byte) []string {
+	var ops []string
+	for _, p := range writes {
+		if p[2] != 0xc1 {
+			ops = append(ops, "spi")
+			continue
+		}
+
+		for i, st := range [2]byte{p[5], p[10]} {
+			switch st {
+			case 0x80:
+				ops = append(ops, string(rune('0'+i))+"+")
+			case 0xc0:
+				ops = append(ops, string(rune('0'+i))+"-")
+			}
+		}
+	}
+
+	return ops
+}
+
+// gpioResponder answers GPIO packets (0xcc) with pins status applying the request.
+// Pins not set keep status bytes of the pins array.
+func gpioResponder(pins *[8]byte) func(p []byte) [][]byte {
+	return func(p []byte) [][]byte {
+		if p[2] != 0xcc {
+			return nil
+		}
+
+		for i, b := range p[5:13] {
+			switch b {
+			case 0xf8: // Output high.
+				pins[i] = 0xc0
+			case 0xf0: // Output low.
+				pins[i] = 0x80
+			case 0xc0: // Input.
+				pins[i] = 0x00
+			}
+		}
+
+		return [][]byte{append([]byte{0x0b, 0x00, 0xcc, 0x08, 0x00}, pins[:]...)}
+	}
+}
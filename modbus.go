@@ -0,0 +1,53 @@
+package ch347
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ModbusException is an exception response of Modbus server.
+type ModbusException struct {
+	Function uint8
+	Code     uint8
+}
+
+func (e *ModbusException) Error() string {
+	return fmt.Sprintf("modbus exception 0x%02x for function 0x%02x", e.Code, e.Function)
+}
+
+// BuildModbusRequest returns Modbus RTU frame with server address, function code, data and CRC.
+//
+// Example:
+//
+//	// Read 9 input registers starting from 0x0000.
+//	p := ch347.BuildModbusRequest(0xf8, 0x04, []byte{0x00, 0x00, 0x00, 0x09})
+func BuildModbusRequest(addr uint8, fn uint8, data []byte) []byte {
+	p := make([]byte, 0, 2+len(data)+2)
+
+	p = append(p, addr, fn)
+	p = append(p, data...)
+
+	crc := CRC16Modbus(p)
+	return append(p, byte(crc), byte(crc>>8))
+}
+
+// ParseModbusResponse verifies CRC of Modbus RTU frame and returns its function code and data.
+//
+// ErrCRC is returned on CRC mismatch, *ModbusException on exception response.
+func ParseModbusResponse(buf []byte) (fn uint8, data []byte, err error) {
+	if len(buf) < 4 {
+		return 0, nil, errors.New("modbus frame is too short")
+	}
+
+	crc := CRC16Modbus(buf[:len(buf)-2])
+	if buf[len(buf)-2] != byte(crc) || buf[len(buf)-1] != byte(crc>>8) {
+		return 0, nil, ErrCRC
+	}
+
+	fn = buf[1]
+	if fn&0x80 != 0 {
+		return fn &^ 0x80, nil, &ModbusException{Function: fn &^ 0x80, Code: buf[2]}
+	}
+
+	return fn, buf[2 : len(buf)-2], nil
+}
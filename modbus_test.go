@@ -0,0 +1,54 @@
+package ch347
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestModbusRoundTrip(t *testing.T) {
+	// Read 10 holding registers of server 1, a well known frame.
+	req := BuildModbusRequest(0x01, 0x03, []byte{0x00, 0x00, 0x00, 0x0a})
+	if want := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0a, 0xc5, 0xcd}; !bytes.Equal(req, want) {
+		t.Fatalf("got % x, want % x", req, want)
+	}
+
+	resp := BuildModbusRequest(0x01, 0x03, []byte{0x04, 0x00, 0x2a, 0x01, 0x00})
+
+	fn, data, err := ParseModbusResponse(resp)
+	if err != nil || fn != 0x03 || !bytes.Equal(data, []byte{0x04, 0x00, 0x2a, 0x01, 0x00}) {
+		t.Fatalf("got 0x%02x, % x, %v", fn, data, err)
+	}
+
+	resp[3] ^= 0x01
+	if _, _, err := ParseModbusResponse(resp); err != ErrCRC {
+		t.Fatalf("corrupted frame: got %v, want ErrCRC", err)
+	}
+
+	if _, _, err := ParseModbusResponse([]byte{0x01, 0x03, 0x00}); err == nil {
+		t.Fatal("short frame accepted")
+	}
+}
+
+func TestModbusException(t *testing.T) {
+	// Illegal data address for read input registers.
+	resp := BuildModbusRequest(0x01, 0x84, []byte{0x02})
+
+	fn, _, err := ParseModbusResponse(resp)
+
+	var exc *ModbusException
+	if !errors.As(err, &exc) || fn != 0x04 || exc.Function != 0x04 || exc.Code != 0x02 {
+		t.Fatalf("got 0x%02x, %v", fn, err)
+	}
+}
+
+func TestCRC(t *testing.T) {
+	// Sensirion datasheet example.
+	if crc := CRC8([]byte{0xbe, 0xef}); crc != 0x92 {
+		t.Fatalf("CRC8 0x%02x, want 0x92", crc)
+	}
+
+	if crc := CRC16Modbus([]byte("123456789")); crc != 0x4b37 {
+		t.Fatalf("CRC16Modbus 0x%04x, want 0x4b37", crc)
+	}
+}
@@ -0,0 +1,64 @@
+package ch347
+
+import "fmt"
+
+// USB vendor ID of CH347.
+const VendorID uint16 = 0x1a86
+
+// Mode represents CH347 operating mode, selected by DTR1 and RTS1 pins at power up.
+type Mode uint8
+
+const (
+	Mode0 Mode = iota // UART0 + UART1, VCP.
+	Mode1             // UART1 + SPI + I2C, VCP + vendor driver.
+	Mode2             // UART1 + SPI + I2C + GPIO, HID. Supported by this package.
+	Mode3             // UART1 + JTAG, VCP + vendor driver.
+)
+
+// USB product IDs of every mode.
+var modePIDs = [...]uint16{
+	Mode0: 0x55da,
+	Mode1: 0x55db,
+	Mode2: 0x55dc,
+	Mode3: 0x55dd,
+}
+
+// ProductID returns USB product ID CH347 enumerates with in this mode, 0 for unknown mode.
+func (m Mode) ProductID() uint16 {
+	if int(m) >= len(modePIDs) {
+		return 0
+	}
+
+	return modePIDs[m]
+}
+
+// ModeFromPID returns operating mode of CH347 enumerated with given USB product ID.
+func ModeFromPID(pid uint16) (Mode, bool) {
+	for m, p := range modePIDs {
+		if p == pid {
+			return Mode(m), true
+		}
+	}
+
+	return 0, false
+}
+
+// CheckMode returns an error telling how to switch the chip to Mode 2
+// if USB device with given IDs is CH347 in a mode not supported by this package.
+func CheckMode(vid, pid uint16) error {
+	if vid != VendorID {
+		return fmt.Errorf("not a CH347 device %04x:%04x", vid, pid)
+	}
+
+	m, ok := ModeFromPID(pid)
+	if !ok {
+		return fmt.Errorf("not a CH347 device %04x:%04x", vid, pid)
+	}
+
+	if m != Mode2 {
+		return fmt.Errorf("CH347 is in mode %d, only HID mode 2 is supported: "+
+			"switch it with the board mode switch or DTR1 and RTS1 pins levels at power up", m)
+	}
+
+	return nil
+}
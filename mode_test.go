@@ -0,0 +1,35 @@
+package ch347
+
+import "testing"
+
+func TestCheckMode(t *testing.T) {
+	for m := Mode0; m <= Mode3; m++ {
+		got, ok := ModeFromPID(m.ProductID())
+		if !ok || got != m {
+			t.Fatalf("mode %d: ModeFromPID returned %d, %v", m, got, ok)
+		}
+
+		err := CheckMode(VendorID, m.ProductID())
+		if (err == nil) != (m == Mode2) {
+			t.Fatalf("mode %d: CheckMode returned %v", m, err)
+		}
+	}
+
+	if err := CheckMode(VendorID, 0x1234); err == nil {
+		t.Fatal("unknown PID accepted")
+	}
+
+	if err := CheckMode(0x1234, Mode2.ProductID()); err == nil {
+		t.Fatal("unknown VID accepted")
+	}
+}
+
+func TestModeProductIDUnknown(t *testing.T) {
+	if pid := Mode(4).ProductID(); pid != 0 {
+		t.Fatalf("got 0x%04x, want 0", pid)
+	}
+
+	if _, ok := ModeFromPID(0); ok {
+		t.Fatal("PID 0 accepted")
+	}
+}
@@ -0,0 +1,87 @@
+package ch347
+
+import (
+	"fmt"
+	"sync"
+)
+
+// TCA9548 is TCA9548A-style I2C multiplexer fanning out to 8 channels.
+type TCA9548 struct {
+	mu   sync.Mutex
+	bus  I2CBus
+	addr uint16
+	ch   int // Selected channel, -1 if unknown.
+}
+
+// NewTCA9548 returns multiplexer on given address, usually 0x70.
+func NewTCA9548(bus I2CBus, addr uint16) *TCA9548 {
+	return &TCA9548{bus: bus, addr: addr, ch: -1}
+}
+
+// SelectChannel connects channel ch (0-7) to the bus, disconnecting others.
+func (m *TCA9548) SelectChannel(ch int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.selectChannel(ch)
+}
+
+func (m *TCA9548) selectChannel(ch int) error {
+	if ch < 0 || ch > 7 {
+		return fmt.Errorf("invalid mux channel %d", ch)
+	}
+
+	err := m.bus.I2C(m.addr, []byte{1 << ch}, nil)
+	if err != nil {
+		m.ch = -1
+		return err
+	}
+
+	m.ch = ch
+	return nil
+}
+
+// Deselect disconnects all channels from the bus.
+func (m *TCA9548) Deselect() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ch = -1
+	return m.bus.I2C(m.addr, []byte{0x00}, nil)
+}
+
+// Channel returns I2CBus talking to devices behind channel ch.
+// Channel is selected before every transfer, unless it's selected already.
+//
+// Selected channel stays connected after the transfer, so devices behind it are
+// on the main bus too: call Deselect before talking to a main bus device with the same address.
+// Selection is cached, so the mux must not be written other than through this TCA9548.
+//
+// Example:
+//
+//	mux := ch347.NewTCA9548(c, 0x70)
+//	err := mux.Channel(2).I2C(0x38, w, nil) // Device behind channel 2.
+//	err = mux.Deselect()
+//	err = c.I2C(0x38, w, nil) // Same address on the main bus.
+func (m *TCA9548) Channel(ch int) I2CBus {
+	return &muxChannel{m: m, ch: ch}
+}
+
+type muxChannel struct {
+	m  *TCA9548
+	ch int
+}
+
+func (c *muxChannel) I2C(addr uint16, w, r []byte) error {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	if c.m.ch != c.ch {
+		err := c.m.selectChannel(c.ch)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.m.bus.I2C(addr, w, r)
+}
@@ -0,0 +1,49 @@
+package ch347
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// i2cLog is I2CBus logging transfers as "addr:w".
+type i2cLog []string
+
+func (l *i2cLog) I2C(addr uint16, w, r []byte) error {
+	*l = append(*l, fmt.Sprintf("%02x:% x", addr, w))
+	return nil
+}
+
+func TestTCA9548(t *testing.T) {
+	var bus i2cLog
+	mux := NewTCA9548(&bus, 0x70)
+
+	ch2 := mux.Channel(2)
+	for i := 0; i < 2; i++ {
+		if err := ch2.I2C(0x38, []byte{0xac}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mux.Channel(5).I2C(0x38, []byte{0xac}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Deselect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ch2.I2C(0x38, []byte{0xac}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Channel is selected once until another one or none is selected.
+	want := i2cLog{"70:04", "38:ac", "38:ac", "70:20", "38:ac", "70:00", "70:04", "38:ac"}
+	if !reflect.DeepEqual(bus, want) {
+		t.Fatalf("got %q, want %q", bus, want)
+	}
+
+	if err := mux.Channel(8).I2C(0x38, nil, nil); err == nil {
+		t.Fatal("channel 8 accepted")
+	}
+}
@@ -0,0 +1,52 @@
+package ch347
+
+import "fmt"
+
+// PinGroup drives several output pins as a parallel bus.
+type PinGroup struct {
+	c    *IO
+	pins []Pin
+}
+
+// NewPinGroup returns a group of pins ordered from the least significant bit.
+//
+// Example:
+//
+//	// 4-bit data bus of HD44780 LCD.
+//	d := ch347.NewPinGroup(c, ch347.GPIO0, ch347.GPIO1, ch347.GPIO2, ch347.GPIO3)
+//	err := d.Write(0x0a)
+func NewPinGroup(c *IO, pins ...Pin) *PinGroup {
+	return &PinGroup{c: c, pins: pins}
+}
+
+// Write sets pins to represent value in a single packet.
+func (g *PinGroup) Write(value uint) error {
+	if value>>len(g.pins) != 0 {
+		return fmt.Errorf("value 0x%x doesn't fit in %d pins", value, len(g.pins))
+	}
+
+	g.c.mu.Lock()
+	defer g.c.mu.Unlock()
+
+	for _, pin := range g.pins {
+		if err := g.c.checkPinRole(pin, PinRoleGPIO); err != nil {
+			return err
+		}
+	}
+
+	err := g.c.writePins(g.levels(value))
+	g.c.metrics.done(&g.c.metrics.gpioOps, 1, err)
+
+	return err
+}
+
+// levels returns pin bytes of GPIO set command representing value.
+func (g *PinGroup) levels(value uint) [8]byte {
+	var set [8]byte
+
+	for i, pin := range g.pins {
+		set[pin] = pinSetByte(true, value&(1<<i) != 0)
+	}
+
+	return set
+}
@@ -0,0 +1,32 @@
+package ch347
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPinGroup(t *testing.T) {
+	var pins [8]byte
+	d := &mockDev{respond: gpioResponder(&pins)}
+	c := &IO{Dev: d}
+
+	// Pins are not in order, GPIO5 is the least significant bit.
+	g := NewPinGroup(c, GPIO5, GPIO0, GPIO7, GPIO2)
+
+	if err := g.Write(0b1011); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{0x0b, 0x00, 0xcc, 0x08, 0x00, 0xf8, 0x00, 0xf8, 0x00, 0x00, 0xf8, 0x00, 0xf0}
+	if writes := d.written(); len(writes) != 1 || !bytes.Equal(writes[0], want) {
+		t.Fatalf("got % x, want % x", writes, want)
+	}
+
+	if err := g.Write(0b10000); err == nil {
+		t.Fatal("value wider than the group accepted")
+	}
+
+	if len(d.written()) != 1 {
+		t.Fatal("packet written for invalid value")
+	}
+}
@@ -0,0 +1,121 @@
+package ch347
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPin is returned for pins other than GPIO0-GPIO7.
+var ErrInvalidPin = errors.New("invalid pin")
+
+// PinRole represents pin function assigned with AssignPin.
+type PinRole uint8
+
+const (
+	PinRoleNone PinRole = iota // Not assigned, no checks are done.
+	PinRoleGPIO
+	PinRoleSCK  // GPIO0.
+	PinRoleMISO // GPIO1.
+	PinRoleCS0  // GPIO2.
+	PinRoleSCL  // GPIO3.
+	PinRoleCS1  // GPIO5.
+)
+
+func (r PinRole) String() string {
+	switch r {
+	case PinRoleNone:
+		return "none"
+	case PinRoleGPIO:
+		return "GPIO"
+	case PinRoleSCK:
+		return "SCK"
+	case PinRoleMISO:
+		return "MISO"
+	case PinRoleCS0:
+		return "CS0"
+	case PinRoleSCL:
+		return "SCL"
+	case PinRoleCS1:
+		return "CS1"
+	}
+
+	return fmt.Sprintf("PinRole(%d)", r)
+}
+
+// Alternate function of every pin.
+var pinAltRoles = [8]PinRole{
+	GPIO0: PinRoleSCK,
+	GPIO1: PinRoleMISO,
+	GPIO2: PinRoleCS0,
+	GPIO3: PinRoleSCL,
+	GPIO5: PinRoleCS1,
+}
+
+// AssignPin commits pin to GPIO or to its alternate function.
+//
+// Once assigned, WritePin fails on pins committed to alternate function,
+// and SetCS/SetCS1, SPI and I2C fail if their pins are committed to GPIO.
+// Assign PinRoleNone to release the pin.
+//
+// Example:
+//
+//	// Use CS1 pin as display reset line.
+//	err := c.AssignPin(ch347.GPIO5, ch347.PinRoleGPIO)
+func (c *IO) AssignPin(pin Pin, role PinRole) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := checkPin(pin); err != nil {
+		return err
+	}
+
+	if role != PinRoleNone && role != PinRoleGPIO && pinAltRoles[pin] != role {
+		return fmt.Errorf("GPIO%d has no %s function", pin, role)
+	}
+
+	if cur := c.pinRoles[pin]; cur != PinRoleNone && role != PinRoleNone && cur != role {
+		return fmt.Errorf("GPIO%d is already assigned to %s", pin, cur)
+	}
+
+	c.pinRoles[pin] = role
+	return nil
+}
+
+// checkPinRole returns an error if pin is assigned to a role other than the given one.
+// Must be called with c.mu held.
+func (c *IO) checkPinRole(pin Pin, role PinRole) error {
+	if err := checkPin(pin); err != nil {
+		return err
+	}
+
+	if cur := c.pinRoles[pin]; cur != PinRoleNone && cur != role {
+		return fmt.Errorf("GPIO%d is assigned to %s", pin, cur)
+	}
+
+	return nil
+}
+
+// checkPin returns ErrInvalidPin for pins other than GPIO0-GPIO7.
+func checkPin(pin Pin) error {
+	if pin > GPIO7 {
+		return fmt.Errorf("%w: GPIO%d", ErrInvalidPin, pin)
+	}
+
+	return nil
+}
+
+// checkSPIPins returns an error if SPI pins are committed to GPIO.
+// MOSI has no GPIO function, so only SCK and MISO are checked.
+func (c *IO) checkSPIPins() error {
+	if err := c.checkPinRole(GPIO0, PinRoleSCK); err != nil {
+		return err
+	}
+
+	return c.checkPinRole(GPIO1, PinRoleMISO)
+}
+
+// checkI2CPins returns an error if I2C pins are committed to GPIO.
+// SDA has no GPIO function, so only SCL is checked.
+func (c *IO) checkI2CPins() error {
+	return c.checkPinRole(GPIO3, PinRoleSCL)
+}
@@ -0,0 +1,55 @@
+package ch347
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPinRoleBuses(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d}
+
+	if err := c.AssignPin(GPIO0, PinRoleGPIO); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SPI([]byte{0x9f}, nil); err == nil {
+		t.Fatal("SPI used SCK committed to GPIO")
+	}
+
+	if err := c.SPIDuplex([]byte{0x9f}, nil); err == nil {
+		t.Fatal("SPIDuplex used SCK committed to GPIO")
+	}
+
+	if err := c.AssignPin(GPIO3, PinRoleGPIO); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.I2C(0x50, []byte{0}, nil); err == nil {
+		t.Fatal("I2C used SCL committed to GPIO")
+	}
+
+	if len(d.written()) != 0 {
+		t.Fatal("packets sent")
+	}
+}
+
+func TestPinRoleInvalidPin(t *testing.T) {
+	c := &IO{Dev: &mockDev{}}
+
+	if err := c.AssignPin(8, PinRoleGPIO); !errors.Is(err, ErrInvalidPin) {
+		t.Fatalf("AssignPin: got %v, want ErrInvalidPin", err)
+	}
+
+	if err := c.WritePins(map[Pin]PinState{8: {Output: true}}); !errors.Is(err, ErrInvalidPin) {
+		t.Fatalf("WritePins: got %v, want ErrInvalidPin", err)
+	}
+
+	if _, err := c.ReadPin(8); !errors.Is(err, ErrInvalidPin) {
+		t.Fatalf("ReadPin: got %v, want ErrInvalidPin", err)
+	}
+
+	if _, err := c.ReadPinDetailed(8); !errors.Is(err, ErrInvalidPin) {
+		t.Fatalf("ReadPinDetailed: got %v, want ErrInvalidPin", err)
+	}
+}
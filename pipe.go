@@ -0,0 +1,56 @@
+package ch347
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// UARTPipe is an io.ReadWriteCloser over UART, which can be closed while a read is pending,
+// like a serial port file. Pass it to code expecting a file-like serial device, or copy it
+// to a PTY to expose UART as a /dev/tty* device.
+//
+// Limitations:
+//   - it's not an *os.File, no file descriptor or ioctls, configure UART with UART.Set.
+//   - no modem control lines.
+//   - Dev must implement ReadWithTimeout for Close to interrupt pending reads.
+//
+// Example:
+//
+//	pipe := ch347.NewUARTPipe(c)
+//	defer pipe.Close()
+//
+//	go io.Copy(pty, pipe)
+//	io.Copy(pipe, pty)
+type UARTPipe struct {
+	u      *UART
+	r      uartIdleReader
+	closed atomic.Bool
+}
+
+// NewUARTPipe returns UARTPipe over u. UART itself is not closed by UARTPipe.Close.
+func NewUARTPipe(u *UART) *UARTPipe {
+	p := &UARTPipe{u: u}
+	p.r = uartIdleReader{u: u, stop: &p.closed}
+
+	return p
+}
+
+// Read reads received data, returning io.EOF once the pipe is closed.
+func (p *UARTPipe) Read(b []byte) (int, error) {
+	return p.r.Read(b)
+}
+
+// Write sends data, returning os.ErrClosed once the pipe is closed.
+func (p *UARTPipe) Write(b []byte) (int, error) {
+	if p.closed.Load() {
+		return 0, os.ErrClosed
+	}
+
+	return p.u.Write(b)
+}
+
+// Close makes pending and further reads return io.EOF.
+func (p *UARTPipe) Close() error {
+	p.closed.Store(true)
+	return nil
+}
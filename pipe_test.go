@@ -0,0 +1,48 @@
+package ch347
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUARTPipe(t *testing.T) {
+	// UART with TX wired to RX.
+	d := &mockDev{respond: func(p []byte) [][]byte { return [][]byte{append([]byte(nil), p...)} }}
+	p := NewUARTPipe(&UART{Dev: d})
+
+	if _, err := p.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(p, b); err != nil || string(b) != "hello" {
+		t.Fatalf("got %q, %v", b, err)
+	}
+
+	// Pending read is interrupted by Close.
+	read := make(chan error)
+	go func() {
+		_, err := p.Read(b)
+		read <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-read:
+		if err != io.EOF {
+			t.Fatalf("Read: got %v, want io.EOF", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read blocked after Close")
+	}
+
+	if _, err := p.Write([]byte("hi")); err != os.ErrClosed {
+		t.Fatalf("Write: got %v, want os.ErrClosed", err)
+	}
+}
@@ -0,0 +1,178 @@
+package ch347
+
+import (
+	"io"
+	"sync"
+)
+
+// SPIPlayer clocks frames out over SPI in the background with double buffering,
+// so the next frame can be prepared while the current one is being transferred.
+type SPIPlayer struct {
+	c  *IO
+	cs int
+
+	free   chan []byte // Buffers available for Submit.
+	frames chan []byte // Buffers waiting to be clocked out.
+	done   chan struct{}
+
+	mu     sync.Mutex
+	err    error
+	paused bool
+	closed bool
+	queued int        // Bytes submitted but not clocked out yet.
+	resume *sync.Cond // Signaled on Resume.
+}
+
+// NewSPIPlayer starts a player clocking frames out with CS0 (cs = 0) or CS1 (cs = 1) asserted around each frame.
+//
+// Example:
+//
+//	pl := ch347.NewSPIPlayer(c, 0)
+//	defer pl.Close()
+//
+//	for frame := range frames {
+//		if err := pl.Submit(frame); err != nil {
+//			return err
+//		}
+//	}
+func NewSPIPlayer(c *IO, cs int) *SPIPlayer {
+	pl := &SPIPlayer{
+		c:      c,
+		cs:     cs,
+		free:   make(chan []byte, 2),
+		frames: make(chan []byte, 2),
+		done:   make(chan struct{}),
+	}
+	pl.resume = sync.NewCond(&pl.mu)
+
+	pl.free <- nil
+	pl.free <- nil
+
+	go pl.run()
+
+	return pl
+}
+
+// Submit queues a copy of the frame. It blocks only when both buffers are in flight.
+//
+// An error of any previous frame transfer is returned, after which the player stops accepting frames.
+// Returns io.ErrClosedPipe after Close.
+func (pl *SPIPlayer) Submit(frame []byte) error {
+	buf := <-pl.free
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if pl.closed {
+		pl.free <- buf
+		return io.ErrClosedPipe
+	}
+
+	if pl.err != nil {
+		pl.free <- buf
+		return pl.err
+	}
+
+	pl.queued += len(frame)
+
+	// Never blocks, there are only two buffers.
+	pl.frames <- append(buf[:0], frame...)
+	return nil
+}
+
+// Pending returns the number of bytes submitted but not clocked out yet,
+// including the frame being transferred right now.
+func (pl *SPIPlayer) Pending() int {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	return pl.queued
+}
+
+// Err returns the first transfer error.
+func (pl *SPIPlayer) Err() error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	return pl.err
+}
+
+// Pause stops clocking out frames once the current one is done, so the display holds it.
+// Submit still accepts frames until both buffers are queued.
+func (pl *SPIPlayer) Pause() {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	pl.paused = true
+}
+
+// Resume continues clocking out queued frames in order.
+func (pl *SPIPlayer) Resume() {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	pl.paused = false
+	pl.resume.Broadcast()
+}
+
+// Close waits for queued frames to be clocked out and stops the player.
+// Paused player is resumed. Returns io.ErrClosedPipe if already closed.
+func (pl *SPIPlayer) Close() error {
+	pl.mu.Lock()
+	if pl.closed {
+		pl.mu.Unlock()
+		return io.ErrClosedPipe
+	}
+
+	pl.closed = true
+	pl.paused = false
+	pl.resume.Broadcast()
+	close(pl.frames)
+	pl.mu.Unlock()
+
+	<-pl.done
+
+	return pl.Err()
+}
+
+func (pl *SPIPlayer) run() {
+	defer close(pl.done)
+
+	for buf := range pl.frames {
+		pl.mu.Lock()
+		for pl.paused {
+			pl.resume.Wait()
+		}
+		pl.mu.Unlock()
+
+		if pl.Err() == nil {
+			err := pl.clock(buf)
+			if err != nil {
+				pl.mu.Lock()
+				pl.err = err
+				pl.mu.Unlock()
+			}
+		}
+
+		pl.mu.Lock()
+		pl.queued -= len(buf)
+		pl.mu.Unlock()
+
+		pl.free <- buf
+	}
+}
+
+// clock sends a frame with CS asserted, all within a single IO lock.
+func (pl *SPIPlayer) clock(buf []byte) error {
+	c := pl.c
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.activity()
+
+	err := c.withCS(pl.cs, func() error { return c.spi(buf, nil) })
+	c.metrics.done(&c.metrics.spiBytes, len(buf), err)
+
+	return err
+}
@@ -0,0 +1,189 @@
+package ch347
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSPIPlayerClosed(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	pl := NewSPIPlayer(&IO{Dev: d, AutoCS: AutoCS1}, 0)
+
+	if err := pl.Submit([]byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"0+", "spi", "0-"}
+	if ops := csOps(d.written()); !reflect.DeepEqual(ops, want) {
+		t.Fatalf("got %v, want %v", ops, want)
+	}
+
+	if err := pl.Submit([]byte{1}); err != io.ErrClosedPipe {
+		t.Fatalf("Submit after Close: got %v, want io.ErrClosedPipe", err)
+	}
+
+	if err := pl.Close(); err != io.ErrClosedPipe {
+		t.Fatalf("second Close: got %v, want io.ErrClosedPipe", err)
+	}
+}
+
+func TestSPIPlayerOrder(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	pl := NewSPIPlayer(&IO{Dev: d}, 1)
+
+	// Both buffers in flight while paused, so the third Submit blocks.
+	pl.Pause()
+
+	submitted := make(chan int)
+	go func() {
+		defer close(submitted)
+
+		for i := 0; i < 20; i++ {
+			frame := make([]byte, 100)
+			for k := range frame {
+				frame[k] = byte(i)
+			}
+
+			if err := pl.Submit(frame); err != nil {
+				t.Error(err)
+				return
+			}
+
+			submitted <- i
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		<-submitted
+	}
+
+	select {
+	case i := <-submitted:
+		t.Fatalf("frame %d submitted with both buffers in flight", i)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if n := pl.Pending(); n != 200 {
+		t.Fatalf("%d bytes pending", n)
+	}
+
+	pl.Resume()
+	for range submitted {
+	}
+
+	if err := pl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every frame clocked out once, in order, with CS1 asserted around it.
+	frames := playedFrames(t, d)
+	for i := 0; i < 20; i++ {
+		if i >= len(frames) || frames[i] != byte(i) {
+			t.Fatalf("got frames %v", frames)
+		}
+	}
+
+	if ops := csOps(d.written()); len(ops) != 60 || ops[0] != "1+" || ops[1] != "spi" || ops[2] != "1-" {
+		t.Fatalf("got %v", ops)
+	}
+}
+
+// playedFrames returns the fill byte of every 100 bytes long frame clocked out.
+func playedFrames(t *testing.T, d *mockDev) []byte {
+	var frames []byte
+	for _, p := range d.written() {
+		if p[2] != 0xc4 {
+			continue
+		}
+
+		if dlen := int(p[3]) | int(p[4])<<8; dlen != 100 || !bytes.Equal(p[5:], bytes.Repeat(p[5:6], 100)) {
+			t.Fatalf("bad frame packet % x", p)
+		}
+
+		frames = append(frames, p[5])
+	}
+
+	return frames
+}
+
+// waitPlayed waits for all submitted frames to be clocked out.
+func waitPlayed(t *testing.T, pl *SPIPlayer) {
+	for end := time.Now().Add(time.Second); pl.Pending() > 0; {
+		if time.Now().After(end) {
+			t.Fatalf("%d bytes still pending", pl.Pending())
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSPIPlayerPause(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	pl := NewSPIPlayer(&IO{Dev: d}, 0)
+
+	frame := func(b byte) []byte { return bytes.Repeat([]byte{b}, 100) }
+
+	pl.Submit(frame(0))
+	pl.Submit(frame(1))
+	waitPlayed(t, pl)
+
+	// Nothing is clocked out while paused.
+	pl.Pause()
+	pl.Submit(frame(2))
+	pl.Submit(frame(3))
+
+	time.Sleep(20 * time.Millisecond)
+
+	if got := playedFrames(t, d); !bytes.Equal(got, []byte{0, 1}) {
+		t.Fatalf("paused: got frames %v", got)
+	}
+
+	// Playback continues from where it stopped.
+	pl.Resume()
+	waitPlayed(t, pl)
+	pl.Submit(frame(4))
+
+	if err := pl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := playedFrames(t, d); !bytes.Equal(got, []byte{0, 1, 2, 3, 4}) {
+		t.Fatalf("got frames %v", got)
+	}
+}
+
+func TestSPIPlayerPending(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	pl := NewSPIPlayer(&IO{Dev: d}, 0)
+	defer pl.Close()
+
+	if n := pl.Pending(); n != 0 {
+		t.Fatalf("%d bytes pending at start", n)
+	}
+
+	pl.Pause()
+
+	for i, want := range []int{100, 150} {
+		if err := pl.Submit(make([]byte, want-pl.Pending())); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := pl.Pending(); n != want {
+			t.Fatalf("frame %d: %d bytes pending, want %d", i, n, want)
+		}
+	}
+
+	pl.Resume()
+	waitPlayed(t, pl)
+
+	if written := len(d.written()); written != 2*3 {
+		t.Fatalf("%d packets written", written)
+	}
+}
@@ -0,0 +1,57 @@
+package ch347
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrInvalidInterval is returned by Poll for non-positive interval.
+var ErrInvalidInterval = errors.New("invalid poll interval")
+
+// Poll calls fn right away and then every interval in a separate goroutine until ctx is cancelled.
+// Errors returned by fn are passed to onErr, if it's not nil, and polling continues.
+//
+// Returned channel is closed once polling goroutine exits.
+// ErrInvalidInterval is returned, and nothing is started, if interval isn't positive.
+//
+// Example:
+//
+//	// Read sensor every second.
+//	done, err := ch347.Poll(ctx, 1*time.Second, func() error {
+//		return c.I2C(0x38, nil, r)
+//	}, func(err error) {
+//		fmt.Println("---", err)
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	<-done
+func Poll(ctx context.Context, interval time.Duration, fn func() error, onErr func(error)) (<-chan struct{}, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			if err := fn(); err != nil && onErr != nil {
+				onErr(err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+
+	return done, nil
+}
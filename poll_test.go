@@ -0,0 +1,66 @@
+package ch347
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var calls, errs atomic.Int32
+	fail := errors.New("sensor busy")
+
+	start := time.Now()
+	done, err := Poll(ctx, 10*time.Millisecond, func() error {
+		if calls.Add(1) == 2 {
+			return fail
+		}
+
+		return nil
+	}, func(err error) {
+		if err == fail {
+			errs.Add(1)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(55 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("not stopped on cancel")
+	}
+
+	// Right away, then every 10ms.
+	n := calls.Load()
+	if n < 4 || n > 7 || errs.Load() != 1 {
+		t.Fatalf("%d calls, %d errors in %v", n, errs.Load(), time.Since(start))
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if calls.Load() != n {
+		t.Fatal("fn called after stop")
+	}
+}
+
+func TestPollInvalidInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		done, err := Poll(context.Background(), interval, func() error {
+			t.Error("fn called")
+			return nil
+		}, nil)
+
+		if !errors.Is(err, ErrInvalidInterval) || done != nil {
+			t.Fatalf("interval %v: got %v", interval, err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package ch347
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrReconnectFailed is returned by ReconnectingUART once MaxRetries Open attempts have failed.
+var ErrReconnectFailed = errors.New("reconnect failed")
+
+// ReconnectingUART wraps UART to survive the device being unplugged and plugged back.
+//
+// Once a read or write fails with a disconnect error, Dev is closed (if it implements io.Closer)
+// and Open is called until it succeeds. Then the last UART.Set configuration is re-applied
+// and the operation is resumed.
+//
+// Example:
+//
+//	r := &ch347.ReconnectingUART{
+//		UART: c,
+//		Open: func() (ch347.HIDDev, error) {
+//			return hid.Open(0x1a86, 0x55dc, serial) // Open the same chip by its serial number.
+//		},
+//		MaxRetries: 30,
+//	}
+//	defer r.Close()
+type ReconnectingUART struct {
+	UART *UART
+
+	// Open opens the device again.
+	Open func() (HIDDev, error)
+
+	// RetryInterval is delay between Open attempts. Defaults to 1 second.
+	RetryInterval time.Duration
+
+	// MaxRetries limits Open attempts per reconnect, after that the operation fails
+	// with ErrReconnectFailed. Zero means retrying until Close.
+	MaxRetries int
+
+	// IsDisconnect reports whether an error means the device is gone and should be reopened.
+	// Other errors are returned as is. Defaults to IsDisconnect function.
+	IsDisconnect func(err error) bool
+
+	// Optional event callbacks.
+	OnDisconnect func(err error)
+	OnReconnect  func()
+
+	mu  sync.RWMutex // Write locked while reconnecting.
+	gen uint64       // Incremented on every reconnect.
+
+	closeOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{} // Closed by Close.
+}
+
+// IsDisconnect is the default ReconnectingUART.IsDisconnect. Timeouts, invalid responses
+// and closed device errors aren't disconnects, any other read or write error is.
+func IsDisconnect(err error) bool {
+	switch {
+	case err == nil, isTimeout(err):
+		return false
+	case errors.Is(err, ErrInvalidResponse), errors.Is(err, os.ErrClosed):
+		return false
+	}
+
+	return true
+}
+
+// Read reads from UART, reconnecting on disconnect errors.
+// Timeouts are returned as ErrTimeout.
+func (r *ReconnectingUART) Read(p []byte) (int, error) {
+	for {
+		if r.closed() {
+			return 0, os.ErrClosed
+		}
+
+		r.mu.RLock()
+		gen := r.gen
+		n, err := r.UART.Read(p)
+		r.mu.RUnlock()
+
+		if !r.isDisconnect(err) {
+			return n, err
+		}
+
+		if err := r.reconnect(gen, err); err != nil {
+			return n, err
+		}
+	}
+}
+
+// Write writes to UART, reconnecting on disconnect errors and resuming with the unsent data.
+func (r *ReconnectingUART) Write(p []byte) (int, error) {
+	pos := 0
+
+	for {
+		if r.closed() {
+			return pos, os.ErrClosed
+		}
+
+		r.mu.RLock()
+		gen := r.gen
+		n, err := r.UART.Write(p[pos:])
+		r.mu.RUnlock()
+
+		pos += n
+		if !r.isDisconnect(err) {
+			return pos, err
+		}
+
+		if err := r.reconnect(gen, err); err != nil {
+			return pos, err
+		}
+	}
+}
+
+// Close stops reconnecting and closes UART. Pending reconnects are aborted,
+// further reads and writes return os.ErrClosed.
+//
+// Note: a Read blocked on the device returns only once the device read returns,
+// use Dev with read timeouts.
+func (r *ReconnectingUART) Close() error {
+	first := false
+	r.closeOnce.Do(func() {
+		close(r.stopChan())
+		first = true
+	})
+
+	if !first {
+		return os.ErrClosed
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.UART.Close()
+}
+
+func (r *ReconnectingUART) stopChan() chan struct{} {
+	r.stopOnce.Do(func() {
+		r.stop = make(chan struct{})
+	})
+
+	return r.stop
+}
+
+func (r *ReconnectingUART) closed() bool {
+	select {
+	case <-r.stopChan():
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *ReconnectingUART) isDisconnect(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if r.IsDisconnect != nil {
+		return r.IsDisconnect(err)
+	}
+
+	return IsDisconnect(err)
+}
+
+// reconnect reopens the device, unless it's already been reopened since gen.
+func (r *ReconnectingUART) reconnect(gen uint64, cause error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Already reconnected by another operation.
+	if r.gen != gen {
+		return nil
+	}
+
+	if r.OnDisconnect != nil {
+		r.OnDisconnect(cause)
+	}
+
+	if cl, ok := r.UART.Dev.(io.Closer); ok {
+		cl.Close()
+	}
+
+	interval := r.RetryInterval
+	if interval == 0 {
+		interval = 1 * time.Second
+	}
+
+	for i := 0; ; i++ {
+		dev, err := r.Open()
+		if err == nil {
+			r.UART.Dev = dev
+
+			if err = r.UART.restore(); err == nil {
+				break
+			}
+
+			if cl, ok := dev.(io.Closer); ok {
+				cl.Close()
+			}
+		}
+
+		if r.MaxRetries > 0 && i+1 >= r.MaxRetries {
+			return fmt.Errorf("%w: %w", ErrReconnectFailed, err)
+		}
+
+		select {
+		case <-r.stopChan():
+			return os.ErrClosed
+		case <-time.After(interval):
+		}
+	}
+
+	r.gen++
+
+	if r.OnReconnect != nil {
+		r.OnReconnect()
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package ch347
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReconnectingUARTTimeout(t *testing.T) {
+	r := &ReconnectingUART{
+		UART: &UART{Dev: &mockDev{}},
+		Open: func() (HIDDev, error) {
+			t.Fatal("reconnected on idle line")
+			return nil, nil
+		},
+	}
+
+	if _, err := r.Read(make([]byte, 16)); err != ErrTimeout {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+}
+
+func TestReconnectingUARTDisconnect(t *testing.T) {
+	next := &mockDev{}
+	next.queue([]byte{2, 0, 'h', 'i'})
+
+	reconnected := false
+	r := &ReconnectingUART{
+		UART:        &UART{Dev: &mockDev{readErr: errors.New("No such device")}},
+		Open:        func() (HIDDev, error) { return next, nil },
+		OnReconnect: func() { reconnected = true },
+	}
+
+	b := make([]byte, 16)
+	n, err := r.Read(b)
+	if err != nil || string(b[:n]) != "hi" {
+		t.Fatalf("got %q, %v", b[:n], err)
+	}
+
+	if !reconnected {
+		t.Fatal("OnReconnect not called")
+	}
+}
+
+func TestReconnectingUARTMaxRetries(t *testing.T) {
+	opens := 0
+	r := &ReconnectingUART{
+		UART: &UART{Dev: &mockDev{writeErr: errors.New("No such device")}},
+		Open: func() (HIDDev, error) {
+			opens++
+			return nil, errors.New("not found")
+		},
+		RetryInterval: time.Millisecond,
+		MaxRetries:    3,
+	}
+
+	if _, err := r.Write([]byte("hi")); !errors.Is(err, ErrReconnectFailed) {
+		t.Fatalf("got %v, want ErrReconnectFailed", err)
+	}
+
+	if opens != 3 {
+		t.Fatalf("got %d Open calls, want 3", opens)
+	}
+}
+
+func TestReconnectingUARTClose(t *testing.T) {
+	r := &ReconnectingUART{
+		UART: &UART{Dev: &mockDev{readErr: errors.New("No such device")}},
+		Open: func() (HIDDev, error) {
+			return nil, errors.New("not found")
+		},
+		RetryInterval: time.Hour,
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := r.Read(make([]byte, 16))
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != os.ErrClosed {
+		t.Fatalf("got %v, want os.ErrClosed", err)
+	}
+
+	if err := r.Close(); err != os.ErrClosed {
+		t.Fatalf("second Close: got %v, want os.ErrClosed", err)
+	}
+}
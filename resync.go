@@ -0,0 +1,42 @@
+package ch347
+
+import "errors"
+
+// ResyncPolicy controls what happens on ErrInvalidResponse.
+type ResyncPolicy uint8
+
+const (
+	// ResyncNone returns ErrInvalidResponse as is.
+	ResyncNone ResyncPolicy = iota
+
+	// ResyncDrain discards pending responses to realign with the device
+	// and still returns ErrInvalidResponse, so next operation succeeds.
+	ResyncDrain
+
+	// ResyncRetry discards pending responses and retries the operation once.
+	//
+	// Note: retried SPI write sends whole w again with CS untouched,
+	// make sure your device tolerates it.
+	ResyncRetry
+)
+
+// resync runs op, recovering from ErrInvalidResponse according to Resync.
+//
+// Stale responses left after an interrupted read make the device look out of sync,
+// draining them realigns the protocol. Dev must implement ReadWithTimeout.
+func (c *IO) resync(op func() error) error {
+	err := op()
+	if c.Resync == ResyncNone || !errors.Is(err, ErrInvalidResponse) {
+		return err
+	}
+
+	if derr := c.drain(); derr != nil {
+		return err
+	}
+
+	if c.Resync != ResyncRetry {
+		return err
+	}
+
+	return op()
+}
@@ -0,0 +1,73 @@
+package ch347
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResync(t *testing.T) {
+	stale := []byte{0x04, 0x00, 0xc0, 0x01, 0x00, 0x00} // Left by an interrupted SPI config.
+
+	for _, tc := range []struct {
+		policy  ResyncPolicy
+		wantErr bool
+		writes  int
+	}{
+		{ResyncNone, true, 1},
+		{ResyncDrain, true, 1},
+		{ResyncRetry, false, 2},
+	} {
+		var pins [8]byte
+		pins[GPIO4] = 0xc0 // Output high.
+
+		d := &mockDev{respond: gpioResponder(&pins)}
+		d.queue(stale)
+
+		c := &IO{Dev: d, Resync: tc.policy}
+
+		lvl, err := c.ReadPin(GPIO4)
+		if gotErr := errors.Is(err, ErrInvalidResponse); gotErr != tc.wantErr || (!gotErr && err != nil) {
+			t.Fatalf("policy %d: got %v", tc.policy, err)
+		}
+
+		if !tc.wantErr && !lvl {
+			t.Fatalf("policy %d: wrong level after retry", tc.policy)
+		}
+
+		if n := len(d.written()); n != tc.writes {
+			t.Fatalf("policy %d: %d requests, want %d", tc.policy, n, tc.writes)
+		}
+
+		if tc.policy == ResyncNone {
+			continue
+		}
+
+		// Drained, next operation is in sync.
+		d.mu.Lock()
+		left := len(d.resps)
+		d.mu.Unlock()
+
+		if left != 0 {
+			t.Fatalf("policy %d: %d responses left", tc.policy, left)
+		}
+
+		if lvl, err := c.ReadPin(GPIO4); err != nil || !lvl {
+			t.Fatalf("policy %d: next read got %v, %v", tc.policy, lvl, err)
+		}
+	}
+}
+
+func TestResyncDrainBounded(t *testing.T) {
+	d := &streamingDev{}
+	c := &IO{Dev: d, Resync: ResyncDrain}
+
+	start := time.Now()
+	if _, err := c.ReadPin(GPIO4); !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("got %v, want ErrInvalidResponse", err)
+	}
+
+	if took := time.Since(start); took > 5*drainTimeout {
+		t.Fatalf("drain took %v", took)
+	}
+}
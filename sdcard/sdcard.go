@@ -0,0 +1,349 @@
+// Package sdcard provides block access to SD cards in SPI mode over CH347.
+//
+// Card connection as follows:
+//
+//	  CH347       SD card
+//	- SCS0    ->  CS (DAT3)
+//	- MOSI    ->  DI (CMD)
+//	- MISO    ->  DO (DAT0)
+//	- SCK     ->  CLK
+//	- 3.3V    ->  VDD
+//	- GND     ->  VSS
+//
+// Configure SPI with mode 0 and MSB byte order before Init.
+// Cards require up to 400KHz clock during initialization, the slowest
+// CH347 clock is 468.75KHz (SPIClock7), which most cards tolerate.
+package sdcard
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/serfreeman1337/go-ch347"
+)
+
+// BlockSize is size of a single block.
+const BlockSize = 512
+
+var (
+	ErrTimeout  = errors.New("sd card timeout")
+	ErrRejected = errors.New("sd card rejected data")
+)
+
+// SD commands.
+const (
+	cmdGoIdleState     = 0
+	cmdSendIfCond      = 8
+	cmdSetBlockLen     = 16
+	cmdReadSingleBlock = 17
+	cmdWriteBlock      = 24
+	cmdAppCmd          = 55
+	cmdReadOCR         = 58
+	acmdSendOpCond     = 41
+)
+
+// R1 response bits.
+const (
+	r1Idle       = 0x01
+	r1IllegalCmd = 0x04
+)
+
+const dataToken = 0xfe
+
+// Card is initialized SD card.
+type Card struct {
+	c    *ch347.IO
+	cs   int
+	sdhc bool // Block addressing.
+}
+
+// Init performs SD card initialization in SPI mode with CS0 (cs = 0) or CS1 (cs = 1).
+func Init(c *ch347.IO, cs int) (*Card, error) {
+	d := &Card{c: c, cs: cs}
+
+	// 74+ clocks with CS deasserted to enter native mode.
+	err := c.SPIClockDummy(-1, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reset and switch to SPI mode.
+	r1, _, err := d.cmd(cmdGoIdleState, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if r1 != r1Idle {
+		return nil, fmt.Errorf("sd card reset failed, r1 0x%02x", r1)
+	}
+
+	// Check voltage range, only SD v2 cards know this command.
+	r1, r7, err := d.cmd(cmdSendIfCond, 0x1aa, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	v2 := r1&r1IllegalCmd == 0
+	if v2 && r7[3] != 0xaa {
+		return nil, fmt.Errorf("sd card check pattern mismatch, got 0x%02x", r7[3])
+	}
+
+	// Wait for initialization to complete.
+	var arg uint32
+	if v2 {
+		arg = 1 << 30 // Host supports high capacity.
+	}
+
+	deadline := time.Now().Add(1 * time.Second)
+	for {
+		r1, err = d.acmd(acmdSendOpCond, arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if r1 == 0x00 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ErrTimeout
+		}
+	}
+
+	if v2 {
+		r1, ocr, err := d.cmd(cmdReadOCR, 0, 4)
+		if err != nil {
+			return nil, err
+		}
+
+		if r1 != 0x00 {
+			return nil, fmt.Errorf("sd card read ocr failed, r1 0x%02x", r1)
+		}
+
+		d.sdhc = ocr[0]&0x40 != 0 // Card capacity status.
+	}
+
+	if !d.sdhc {
+		r1, _, err = d.cmd(cmdSetBlockLen, BlockSize, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if r1 != 0x00 {
+			return nil, fmt.Errorf("sd card set block length failed, r1 0x%02x", r1)
+		}
+	}
+
+	return d, nil
+}
+
+// ReadBlock reads block number lba into p, which must be BlockSize bytes long.
+func (d *Card) ReadBlock(lba uint32, p []byte) error {
+	if len(p) != BlockSize {
+		return fmt.Errorf("buffer must be %d bytes long", BlockSize)
+	}
+
+	err := d.selectCard(true)
+	if err != nil {
+		return err
+	}
+	defer d.selectCard(false)
+
+	r1, err := d.command(cmdReadSingleBlock, d.blockAddr(lba))
+	if err != nil {
+		return err
+	}
+
+	if r1 != 0x00 {
+		return fmt.Errorf("sd card read failed, r1 0x%02x", r1)
+	}
+
+	// Wait for data token.
+	b, err := d.waitFor(100*time.Millisecond, func(b byte) bool { return b != 0xff })
+	if err != nil {
+		return err
+	}
+
+	if b != dataToken {
+		return fmt.Errorf("sd card read failed, data error token 0x%02x", b)
+	}
+
+	r := make([]byte, BlockSize+2) // Block and CRC16.
+	err = d.c.SPI(nil, r)
+	if err != nil {
+		return err
+	}
+
+	copy(p, r)
+	return nil
+}
+
+// WriteBlock writes p, which must be BlockSize bytes long, to block number lba.
+func (d *Card) WriteBlock(lba uint32, p []byte) error {
+	if len(p) != BlockSize {
+		return fmt.Errorf("buffer must be %d bytes long", BlockSize)
+	}
+
+	err := d.selectCard(true)
+	if err != nil {
+		return err
+	}
+	defer d.selectCard(false)
+
+	r1, err := d.command(cmdWriteBlock, d.blockAddr(lba))
+	if err != nil {
+		return err
+	}
+
+	if r1 != 0x00 {
+		return fmt.Errorf("sd card write failed, r1 0x%02x", r1)
+	}
+
+	// Gap byte, data token, block and dummy CRC16.
+	w := make([]byte, 0, 2+BlockSize+2)
+	w = append(w, 0xff, dataToken)
+	w = append(w, p...)
+	w = append(w, 0xff, 0xff)
+
+	resp := make([]byte, 1)
+	err = d.c.SPI(w, resp)
+	if err != nil {
+		return err
+	}
+
+	if resp[0]&0x1f != 0x05 {
+		return ErrRejected
+	}
+
+	// Card holds DO low while programming.
+	_, err = d.waitFor(500*time.Millisecond, func(b byte) bool { return b != 0x00 })
+	return err
+}
+
+func (d *Card) blockAddr(lba uint32) uint32 {
+	if d.sdhc {
+		return lba
+	}
+
+	return lba * BlockSize
+}
+
+// cmd sends a command in its own CS assertion and reads R1 and n more response bytes.
+func (d *Card) cmd(cmd byte, arg uint32, n int) (byte, []byte, error) {
+	err := d.selectCard(true)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer d.selectCard(false)
+
+	r1, err := d.command(cmd, arg)
+	if err != nil || n == 0 {
+		return r1, nil, err
+	}
+
+	r := make([]byte, n)
+	err = d.c.SPI(nil, r)
+
+	return r1, r, err
+}
+
+// acmd sends application specific command.
+func (d *Card) acmd(cmd byte, arg uint32) (byte, error) {
+	_, _, err := d.cmd(cmdAppCmd, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	r1, _, err := d.cmd(cmd, arg, 0)
+	return r1, err
+}
+
+// command sends a command frame and returns R1 response. CS must be asserted.
+func (d *Card) command(cmd byte, arg uint32) (byte, error) {
+	err := d.c.SPI(commandFrame(cmd, arg), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// R1 comes within 8 bytes, its bit 7 is always 0.
+	r := make([]byte, 1)
+	for i := 0; i < 8; i++ {
+		err = d.c.SPI(nil, r)
+		if err != nil {
+			return 0, err
+		}
+
+		if r[0]&0x80 == 0 {
+			return r[0], nil
+		}
+	}
+
+	return 0, ErrTimeout
+}
+
+// waitFor reads bytes until ok returns true.
+func (d *Card) waitFor(timeout time.Duration, ok func(byte) bool) (byte, error) {
+	r := make([]byte, 1)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		err := d.c.SPI(nil, r)
+		if err != nil {
+			return 0, err
+		}
+
+		if ok(r[0]) {
+			return r[0], nil
+		}
+
+		if time.Now().After(deadline) {
+			return 0, ErrTimeout
+		}
+	}
+}
+
+// selectCard asserts or deasserts CS. After deassertion, one more byte is clocked
+// so the card releases DO line.
+func (d *Card) selectCard(on bool) error {
+	var err error
+	if d.cs == 1 {
+		err = d.c.SetCS1(on)
+	} else {
+		err = d.c.SetCS(on)
+	}
+
+	if err != nil || on {
+		return err
+	}
+
+	return d.c.SPIClockDummy(-1, 1)
+}
+
+// commandFrame returns 6 bytes long command frame: start bits with index, argument, CRC7 and stop bit.
+func commandFrame(cmd byte, arg uint32) []byte {
+	p := []byte{
+		0x40 | cmd,
+		byte(arg >> 24), byte(arg >> 16), byte(arg >> 8), byte(arg),
+		0x00,
+	}
+
+	p[5] = crc7(p[:5])<<1 | 0x01
+	return p
+}
+
+// crc7 returns CRC7 with x^7 + x^3 + 1 polynomial.
+func crc7(p []byte) byte {
+	var crc byte
+
+	for _, a := range p {
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if (a<<i^crc)&0x80 != 0 {
+				crc ^= 0x09
+			}
+		}
+	}
+
+	return crc & 0x7f
+}
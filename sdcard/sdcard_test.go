@@ -0,0 +1,219 @@
+package sdcard
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/serfreeman1337/go-ch347"
+)
+
+func TestCommandFrame(t *testing.T) {
+	// Frames with well known CRC7.
+	for _, tc := range []struct {
+		cmd  byte
+		arg  uint32
+		want []byte
+	}{
+		{cmdGoIdleState, 0, []byte{0x40, 0x00, 0x00, 0x00, 0x00, 0x95}},
+		{cmdSendIfCond, 0x1aa, []byte{0x48, 0x00, 0x00, 0x01, 0xaa, 0x87}},
+		{cmdReadSingleBlock, 0, []byte{0x51, 0x00, 0x00, 0x00, 0x00, 0x55}},
+	} {
+		if got := commandFrame(tc.cmd, tc.arg); !bytes.Equal(got, tc.want) {
+			t.Fatalf("CMD%d: got % x, want % x", tc.cmd, got, tc.want)
+		}
+	}
+}
+
+// cardSim is CH347 SPI interface with SDHC card attached.
+type cardSim struct {
+	t      *testing.T
+	resps  [][]byte // HID responses.
+	opLeft int      // Data bytes left in the current SPI write operation.
+
+	out     []byte   // Bytes card sends on MISO next, 0xff once they run out.
+	cmds    []string // Commands received, like "CMD17 00000005".
+	app     bool     // CMD55 received, next command is ACMD.
+	ready   bool
+	writing bool // Waiting for data block.
+	block   []byte
+}
+
+func (d *cardSim) SendFeatureReport(p []byte) (int, error) { return len(p), nil }
+
+func (d *cardSim) Write(p []byte) (int, error) {
+	data := p[2:]
+
+	switch {
+	case d.opLeft > 0: // Write operation continues.
+	case data[0] == 0xc1: // CS.
+		return len(p), nil
+	case data[0] == 0xc3:
+		d.read(int(data[3]) | int(data[4])<<8)
+		return len(p), nil
+	case data[0] == 0xc4:
+		d.opLeft = int(data[1]) | int(data[2])<<8
+		data = data[3:]
+	default:
+		d.t.Fatalf("unexpected packet % x", p)
+	}
+
+	d.opLeft -= len(data)
+	d.resps = append(d.resps, []byte{0x03, 0x00, 0xc4, 0x01, 0x00})
+	d.receive(data)
+
+	return len(p), nil
+}
+
+func (d *cardSim) Read(p []byte) (int, error) {
+	if len(d.resps) == 0 {
+		d.t.Fatal("no response")
+	}
+
+	r := d.resps[0]
+	d.resps = d.resps[1:]
+
+	return copy(p, r), nil
+}
+
+// read responds to SPI read of n bytes.
+func (d *cardSim) read(n int) {
+	for n > 0 {
+		dlen := min(n, 507)
+
+		resp := []byte{byte(dlen + 3), byte((dlen + 3) >> 8), 0xc3, byte(dlen), byte(dlen >> 8)}
+		for i := 0; i < dlen; i++ {
+			b := byte(0xff)
+			if len(d.out) > 0 {
+				b, d.out = d.out[0], d.out[1:]
+			}
+
+			resp = append(resp, b)
+		}
+
+		d.resps = append(d.resps, resp)
+		n -= dlen
+	}
+}
+
+// receive handles bytes written on MOSI.
+func (d *cardSim) receive(data []byte) {
+	if d.writing {
+		d.block = append(d.block, data...)
+
+		// Gap, token, block and CRC.
+		if len(d.block) == 2+BlockSize+2 {
+			if d.block[1] != dataToken {
+				d.t.Fatalf("data token 0x%02x", d.block[1])
+			}
+
+			d.block = d.block[2 : 2+BlockSize]
+			d.writing = false
+			d.out = []byte{0xe5, 0x00, 0x00, 0xff} // Accepted, then busy.
+		}
+
+		return
+	}
+
+	if len(data) != 6 || data[0]&0xc0 != 0x40 {
+		d.t.Fatalf("bad command frame % x", data)
+	}
+
+	cmd, arg := data[0]&0x3f, uint32(data[1])<<24|uint32(data[2])<<16|uint32(data[3])<<8|uint32(data[4])
+	if data[5] != crc7(data[:5])<<1|1 {
+		d.out = []byte{0xff, 0x08} // CRC error.
+		return
+	}
+
+	name := "CMD"
+	if d.app {
+		name = "ACMD"
+	}
+	d.app = false
+	d.cmds = append(d.cmds, fmt.Sprintf("%s%d %08x", name, cmd, arg))
+
+	r1 := byte(0x00)
+	if !d.ready {
+		r1 = r1Idle
+	}
+
+	switch {
+	case name == "CMD" && cmd == cmdGoIdleState:
+		d.out = []byte{0xff, r1Idle}
+	case name == "CMD" && cmd == cmdSendIfCond:
+		d.out = []byte{0xff, r1, 0x00, 0x00, 0x01, 0xaa}
+	case name == "CMD" && cmd == cmdAppCmd:
+		d.app = true
+		d.out = []byte{0xff, r1}
+	case name == "ACMD" && cmd == acmdSendOpCond:
+		if d.ready = len(d.cmds) > 4; d.ready { // Ready on the second try.
+			r1 = 0x00
+		}
+
+		d.out = []byte{0xff, r1}
+	case name == "CMD" && cmd == cmdReadOCR:
+		d.out = []byte{0xff, r1, 0xc0, 0xff, 0x80, 0x00} // High capacity.
+	case name == "CMD" && cmd == cmdReadSingleBlock:
+		d.out = append([]byte{0xff, 0x00, 0xff, dataToken}, d.block...)
+		d.out = append(d.out, 0x00, 0x00)
+	case name == "CMD" && cmd == cmdWriteBlock:
+		d.out = []byte{0xff, 0x00}
+		d.writing, d.block = true, nil
+	default:
+		d.out = []byte{0xff, r1IllegalCmd}
+	}
+}
+
+func TestCard(t *testing.T) {
+	sim := &cardSim{t: t}
+	c := &ch347.IO{Dev: sim}
+
+	card, err := Init(c, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"CMD0 00000000", "CMD8 000001aa",
+		"CMD55 00000000", "ACMD41 40000000",
+		"CMD55 00000000", "ACMD41 40000000",
+		"CMD58 00000000",
+	}
+	if !reflect.DeepEqual(sim.cmds, want) {
+		t.Fatalf("init: got %q, want %q", sim.cmds, want)
+	}
+
+	if !card.sdhc {
+		t.Fatal("high capacity card not detected")
+	}
+
+	sim.cmds = nil
+
+	w := make([]byte, BlockSize)
+	for i := range w {
+		w[i] = byte(i * 3)
+	}
+
+	if err := card.WriteBlock(5, w); err != nil {
+		t.Fatal(err)
+	}
+
+	r := make([]byte, BlockSize)
+	if err := card.ReadBlock(5, r); err != nil {
+		t.Fatal(err)
+	}
+
+	// Block addressing.
+	if want := []string{"CMD24 00000005", "CMD17 00000005"}; !reflect.DeepEqual(sim.cmds, want) {
+		t.Fatalf("got %q, want %q", sim.cmds, want)
+	}
+
+	if !bytes.Equal(r, w) {
+		t.Fatal("read block differs from written one")
+	}
+
+	if len(sim.resps) != 0 {
+		t.Fatalf("%d responses left", len(sim.resps))
+	}
+}
@@ -0,0 +1,55 @@
+package ch347
+
+import (
+	"sync"
+	"time"
+)
+
+// SerializingHIDDev makes a single HIDDev safe to share between IO and UART,
+// or any other concurrent users, by never letting its calls run at the same time.
+//
+// Note: the lock is held for the whole call, so a blocking Read holds off writes
+// until it returns. Prefer ReadWithTimeout capable devices.
+//
+// Example:
+//
+//	dev := &ch347.SerializingHIDDev{Dev: shared}
+//	c := &ch347.IO{Dev: dev}
+//	u := &ch347.UART{Dev: dev}
+type SerializingHIDDev struct {
+	mu  sync.Mutex
+	Dev HIDDev
+}
+
+func (d *SerializingHIDDev) Read(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.Dev.Read(p)
+}
+
+// ReadWithTimeout calls Dev ReadWithTimeout if it's implemented, otherwise falls back to Read.
+func (d *SerializingHIDDev) ReadWithTimeout(p []byte, timeout time.Duration) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if td, ok := d.Dev.(timeoutReader); ok {
+		return td.ReadWithTimeout(p, timeout)
+	}
+
+	return d.Dev.Read(p)
+}
+
+func (d *SerializingHIDDev) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.Dev.Write(p)
+}
+
+func (d *SerializingHIDDev) SendFeatureReport(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.Dev.SendFeatureReport(p)
+}
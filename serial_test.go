@@ -0,0 +1,72 @@
+package ch347
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// overlapDev counts calls running at the same time.
+type overlapDev struct {
+	active  atomic.Int32
+	overlap atomic.Int32
+}
+
+func (d *overlapDev) call() {
+	if d.active.Add(1) > 1 {
+		d.overlap.Add(1)
+	}
+
+	time.Sleep(100 * time.Microsecond)
+	d.active.Add(-1)
+}
+
+func (d *overlapDev) Read(p []byte) (int, error) {
+	d.call()
+	return len(p), nil
+}
+
+func (d *overlapDev) ReadWithTimeout(p []byte, _ time.Duration) (int, error) {
+	d.call()
+	return len(p), nil
+}
+
+func (d *overlapDev) Write(p []byte) (int, error) {
+	d.call()
+	return len(p), nil
+}
+
+func (d *overlapDev) SendFeatureReport(p []byte) (int, error) {
+	d.call()
+	return len(p), nil
+}
+
+func TestSerializingHIDDev(t *testing.T) {
+	d := &overlapDev{}
+	s := &SerializingHIDDev{Dev: d}
+
+	calls := []func(){
+		func() { s.Read(make([]byte, 4)) },
+		func() { s.ReadWithTimeout(make([]byte, 4), time.Millisecond) },
+		func() { s.Write(make([]byte, 4)) },
+		func() { s.SendFeatureReport(make([]byte, 4)) },
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(call func()) {
+			defer wg.Done()
+
+			for k := 0; k < 20; k++ {
+				call()
+			}
+		}(calls[i%len(calls)])
+	}
+	wg.Wait()
+
+	if n := d.overlap.Load(); n != 0 {
+		t.Fatalf("%d overlapping calls", n)
+	}
+}
@@ -2,12 +2,41 @@ package ch347
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
-	ErrInvalidResponse = errors.New("invalid response")
+	ErrInvalidResponse  = errors.New("invalid response")
+	ErrSPINotConfigured = errors.New("spi is not configured")
+	ErrSPILength        = errors.New("spi w and r lengths differ")
+	ErrInvalidCS        = errors.New("invalid cs")
 )
 
+// DeviceError is returned when device response doesn't match the expected one.
+// It unwraps to ErrInvalidResponse.
+type DeviceError struct {
+	Op       string // Operation, e.g. "spi config".
+	Expected []byte // Expected bytes at the start of the response command, after length.
+	Got      []byte // Whole response, including length.
+}
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf("%s: %v. expected (% x), got (% x)", e.Op, ErrInvalidResponse, e.Expected, e.Got)
+}
+
+func (e *DeviceError) Unwrap() error {
+	return ErrInvalidResponse
+}
+
+// checkResponse returns DeviceError if response p doesn't have expected bytes after its length.
+func checkResponse(op string, p []byte, expected ...byte) error {
+	if len(p) >= 2+len(expected) && string(p[2:2+len(expected)]) == string(expected) {
+		return nil
+	}
+
+	return &DeviceError{Op: op, Expected: expected, Got: append([]byte(nil), p...)}
+}
+
 type SPIMode uint8
 
 const (
@@ -47,13 +76,82 @@ const (
 //   - SPIClock6 - 937.5 KHz.
 //   - SPIClock7 - 468.75 KHz.
 //
+// Mode, clock and byte order are replaced, other configuration fields are preserved:
+// CS polarity set by SetCSPolarity stays in effect. Rest of the configuration packet is fixed.
+//
 // # Note:
 //
-// If you want to initialize both I2C and SPI, then I2C should be initialized first.
+// Setting I2C resets SPI configuration, so it's re-applied before the next SPI transfer
+// after SetI2C, which costs one more round trip once. Plain I2C transfers keep it,
+// so both buses can be used without reconfiguring between operations.
 func (c *IO) SetSPI(mode SPIMode, clock SPIClock, byteOrder SPIByteOrder) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	err := c.setSPI(mode, clock, byteOrder)
+	if err != nil {
+		return err
+	}
+
+	if c.DriveCSIdle {
+		return c.writeCS([2]byte{0xc0, 0xc0})
+	}
+
+	return nil
+}
+
+// NearestSPIClock returns the fastest clock not exceeding hz.
+// Requests above 60 MHz get SPIClock0, below 468.75 KHz get SPIClock7, the slowest one.
+func NearestSPIClock(hz uint32) SPIClock {
+	for clock := SPIClock0; clock < SPIClock7; clock++ {
+		if clock.Hz() <= float64(hz) {
+			return clock
+		}
+	}
+
+	return SPIClock7
+}
+
+// SetSPIHz configures the interface like SetSPI with the fastest clock not exceeding hz,
+// and returns the clock chosen. Check it for requests below 468.75 KHz, which get the slowest clock.
+//
+// Example:
+//
+//	clock, err := c.SetSPIHz(ch347.SPIMode0, 20_000_000, ch347.SPIByteOrderMSB) // SPIClock2, 15 MHz.
+func (c *IO) SetSPIHz(mode SPIMode, hz uint32, byteOrder SPIByteOrder) (SPIClock, error) {
+	clock := NearestSPIClock(hz)
+	return clock, c.SetSPI(mode, clock, byteOrder)
+}
+
+// Hz returns clock frequency: 60 MHz divided by 2 for every step down to 468.75 KHz.
+func (clock SPIClock) Hz() float64 {
+	return 60_000_000 / float64(uint32(1)<<clock)
+}
+
+// GetSPIHz returns frequency of the clock set by SetSPI. ok is false if SPI was not configured yet.
+func (c *IO) GetSPIHz() (hz float64, ok bool) {
+	_, clock, _, ok := c.GetSPI()
+	if !ok {
+		return 0, false
+	}
+
+	return clock.Hz(), true
+}
+
+func (c *IO) setSPI(mode SPIMode, clock SPIClock, byteOrder SPIByteOrder) error {
+	return c.resync(func() error { return c.setSPIOnce(mode, clock, byteOrder) })
+}
+
+// refreshSPI re-applies SPI configuration reset by SetI2C.
+func (c *IO) refreshSPI() error {
+	if !c.spiStale {
+		return nil
+	}
+
+	return c.setSPI(c.spiMode, c.spiClock, c.spiByteOrder)
+}
+
+func (c *IO) setSPIOnce(mode SPIMode, clock SPIClock, byteOrder SPIByteOrder) error {
 	p := make([]byte, 0, 29)
 
 	p = append(p, 0x1d, 0x00)
@@ -120,12 +218,12 @@ func (c *IO) SetSPI(mode SPIMode, clock SPIClock, byteOrder SPIByteOrder) error
 	// 25 byte - CS Polarity
 	// 0x80 - active high CS0
 	// 0x40 - active high CS1
-	p = append(p, 0x00)
+	p = append(p, c.csPolarity)
 
 	// 26-30
 	p = append(p, 0x00, 0x00, 0x00, 0x00)
 
-	_, err := c.Dev.Write(p)
+	err := c.write(p)
 	if err != nil {
 		return err
 	}
@@ -133,24 +231,158 @@ func (c *IO) SetSPI(mode SPIMode, clock SPIClock, byteOrder SPIByteOrder) error
 	// Read response.
 	p = p[:6]
 	// 0400 c0 01 00 00
-	_, err = c.Dev.Read(p)
+	_, err = c.read(p)
 	if err != nil {
 		return err
 	}
 
-	if p[2] != 0xc0 && p[3] != 0x01 {
-		// return fmt.Errorf("invalid device response. expected (0xc0 0x01), got (0x%02x 0x%02x)", p[2], p[3])
-		return ErrInvalidResponse
+	if err = checkResponse("spi config", p, 0xc0, 0x01); err != nil {
+		return err
 	}
 
+	c.spiSet, c.spiStale = true, false
+	c.spiMode, c.spiClock, c.spiByteOrder = mode, clock, byteOrder
+
 	return nil
 }
 
+// GetSPI returns SPI configuration set by SetSPI. ok is false if SPI was not configured yet.
+func (c *IO) GetSPI() (mode SPIMode, clock SPIClock, byteOrder SPIByteOrder, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.spiMode, c.spiClock, c.spiByteOrder, c.spiSet
+}
+
+// ProbeSPIClocks returns clocks at which the device works, from fastest to slowest.
+//
+// Every clock is set with mode and byte order from last SetSPI call, then fn is called
+// to validate the device, e.g. by reading flash JEDEC ID. Clock counts as working
+// if fn returns true without an error. Previous configuration is restored afterwards.
+//
+// Errors of fn don't stop probing, they are returned joined along with working clocks.
+//
+// Example:
+//
+//	clocks, err := c.ProbeSPIClocks(func(ch347.SPIClock) (bool, error) {
+//		r := make([]byte, 3)
+//		c.SetCS(true)
+//		err := c.SPI([]byte{0x9f}, r)
+//		c.SetCS(false)
+//		return r[0] != 0x00 && r[0] != 0xff, err
+//	})
+func (c *IO) ProbeSPIClocks(fn func(SPIClock) (bool, error)) ([]SPIClock, error) {
+	mode, clock, byteOrder, ok := c.GetSPI()
+
+	var (
+		clocks []SPIClock
+		errs   []error
+	)
+
+	for cl := SPIClock0; cl <= SPIClock7; cl++ {
+		err := c.SetSPI(mode, cl, byteOrder)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		works, err := fn(cl)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%.0f Hz: %w", cl.Hz(), err))
+			continue
+		}
+
+		if works {
+			clocks = append(clocks, cl)
+		}
+	}
+
+	if ok {
+		errs = append(errs, c.SetSPI(mode, clock, byteOrder))
+	}
+
+	return clocks, errors.Join(errs...)
+}
+
+// ResetSPI realigns the protocol after an interrupted SPI transfer.
+//
+// It discards any pending device responses and re-applies last SPI configuration set by SetSPI.
+// No dedicated abort command is known, so this is the cheapest known way to recover.
+// Dev must implement ReadWithTimeout to discard pending responses.
+func (c *IO) ResetSPI() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.drain()
+	if err != nil {
+		return err
+	}
+
+	if !c.spiSet {
+		return nil
+	}
+
+	return c.setSPI(c.spiMode, c.spiClock, c.spiByteOrder)
+}
+
 // SPI performs write and read operations.
+//
+// Transfer is half-duplex: all of w is written first, then len(r) bytes are read.
+// Lengths of w and r are independent of each other:
+//   - w only - bytes are written, MISO is ignored.
+//   - r only - len(r) bytes are clocked out with the default data (0xff) and MISO is captured into r.
+//   - both - w is written, then len(r) bytes are read. MISO bytes clocked during the write are discarded.
+//
+// SPIStrictLength doesn't apply here, it's for SPIDuplex where w and r bytes pair up.
+//
+// Large writes are split into several operations internally. SPI never touches CS,
+// so CS asserted with SetCS stays asserted for the whole transfer.
+// With AutoCS set, the selected CS is asserted for the transfer instead.
+//
+// Example:
+//
+//	// Read flash JEDEC ID. r holds bytes clocked after the 0x9f instruction.
+//	r := make([]byte, 3)
+//	err := c.SPI([]byte{0x9f}, r)
 func (c *IO) SPI(w, r []byte) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.activity()
+
+	err := c.withAutoCS(func() error { return c.spi(w, r) })
+	c.metrics.done(&c.metrics.spiBytes, len(w)+len(r), err)
+
+	return err
+}
+
+// AutoCS selects CS line asserted by SPI around every transfer.
+type AutoCS uint8
+
+const (
+	AutoCSOff AutoCS = iota // CS is controlled with SetCS.
+	AutoCS0                 // CS0 is asserted around every SPI call.
+	AutoCS1                 // CS1 is asserted around every SPI call.
+)
+
+// withAutoCS runs fn with CS selected by AutoCS asserted, if any.
+func (c *IO) withAutoCS(fn func() error) error {
+	return c.withCS(int(c.AutoCS)-1, fn)
+}
+
+func (c *IO) spi(w, r []byte) error {
+	if err := c.checkSPIPins(); err != nil {
+		return err
+	}
+
+	if err := c.refreshSPI(); err != nil {
+		return err
+	}
+
+	return c.resync(func() error { return c.spiOnce(w, r) })
+}
+
+func (c *IO) spiOnce(w, r []byte) error {
 	const (
 		CmdSPIWrite byte = 0xc4
 		CmdSPIRead  byte = 0xc3
@@ -161,37 +393,33 @@ func (c *IO) SPI(w, r []byte) error {
 	if wlen := len(w); wlen > 0 {
 		sent := 0
 		write := func(finish bool) error {
-			if len(p) <= 2 { // Nothing to write.
-				return nil
-			}
-
-			// Set length in the first 2 bytes.
-			plen := len(p) - 2
-			p[0] = byte(plen & 0xff)
-			p[1] = byte((plen >> 8) & 0xff)
+			// Packet may be already sent when data ends right at its boundary,
+			// but its response still has to be read.
+			if len(p) > 2 {
+				// Set length in the first 2 bytes.
+				plen := len(p) - 2
+				p[0] = byte(plen & 0xff)
+				p[1] = byte((plen >> 8) & 0xff)
+
+				err := c.write(p)
+				if err != nil {
+					return err
+				}
 
-			_, err := c.Dev.Write(p)
-			if err != nil {
-				return err
+				sent++
 			}
 
-			sent++
-
 			// Confirm writes.
 			if finish { // CH347 will perform SPI transfer as soon as all responses are read.
 				for ; sent > 0; sent-- { // For every sent packet.
 					p = p[:5]
-					_, err = c.Dev.Read(p)
+					_, err := c.read(p)
 					if err != nil {
 						return err
 					}
 
-					if p[2] != 0xc4 && p[3] != 0x01 {
-						// return fmt.Errorf("invalid device response. expected (0x%02x 0x%02x %02x 0x%02x). got (0x%02x 0x%02x %02x 0x%02x)",
-						// 	0x03, 0x00, 0xc4, 0x01,
-						// 	p[0], p[1], p[2], p[3],
-						// )
-						return ErrInvalidResponse
+					if err = checkResponse("spi write", p, 0xc4, 0x01); err != nil {
+						return err
 					}
 				}
 			}
@@ -200,9 +428,11 @@ func (c *IO) SPI(w, r []byte) error {
 			return nil
 		}
 
-		const maxDataLen = 509 // Maximum data length in a single packet.
+		// Length fields are 16-bit: packet length never exceeds maxDataLen
+		// and operation length never exceeds maxOpLen, both fit.
+		maxDataLen := c.packetSize() - 3 // Maximum data length in a single packet, 509 for 512 bytes packets.
 		// One write operation can consist of a maximum of 63 packets. Ensure this by limiting single operation data length.
-		const maxOpLen = 32768 - maxDataLen*2 // Max data length of single SPI Write (0xc4) operation.
+		maxOpLen := min(32768-maxDataLen*2, 63*maxDataLen) // Max data length of single SPI Write (0xc4) operation.
 
 		var pos, plen, nlen, olen, dlen int
 		for pos < wlen {
@@ -270,7 +500,7 @@ func (c *IO) SPI(w, r []byte) error {
 		p[7] = byte((rlen >> 16) & 0xff)
 		p[8] = byte((rlen >> 24) & 0xff)
 
-		_, err := c.Dev.Write(p)
+		err := c.write(p)
 		if err != nil {
 			return err
 		}
@@ -284,13 +514,14 @@ func (c *IO) SPI(w, r []byte) error {
 			}
 
 			p = p[:5+dlen]
-			_, err = c.Dev.Read(p)
+			_, err = c.read(p)
 			if err != nil {
 				return err
 			}
 
-			if p[2] != CmdSPIRead || ((int(p[4])<<8)|int(p[3])) != dlen {
-				return ErrInvalidResponse
+			err = checkResponse("spi read", p, CmdSPIRead, byte(dlen&0xff), byte((dlen>>8)&0xff))
+			if err != nil {
+				return err
 			}
 
 			copy(r[pos:pos+dlen], p[5:5+dlen])
@@ -301,6 +532,259 @@ func (c *IO) SPI(w, r []byte) error {
 	return nil
 }
 
+// SetCSPolarity makes CS0 (cs = 0) or CS1 (cs = 1) active high. CS lines are active low by default.
+// SetCS and SetCS1 keep meaning assert and deassert, the chip drives the level accordingly.
+//
+// Polarity is a part of SPI configuration: it's applied right away if SPI is configured,
+// or by the next SetSPI otherwise. ErrInvalidCS is returned for other cs values.
+func (c *IO) SetCSPolarity(cs int, activeHigh bool) error {
+	if err := checkCS(cs); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bit := [2]byte{0x80, 0x40}[cs]
+	if activeHigh {
+		c.csPolarity |= bit
+	} else {
+		c.csPolarity &^= bit
+	}
+
+	if !c.spiSet {
+		return nil
+	}
+
+	return c.setSPI(c.spiMode, c.spiClock, c.spiByteOrder)
+}
+
+// SPIDuplex performs full-duplex transfer: every byte of w is clocked out on MOSI while
+// a byte is captured from MISO into r at the same position.
+//
+// If r is longer than w, extra bytes are clocked out as 0xff, the default data. If w is longer,
+// MISO bytes past len(r) are discarded, or ErrSPILength is returned with SPIStrictLength set.
+// Like SPI, it never touches CS unless AutoCS is set.
+//
+// Unlike SPI, which reads after writing (what command/response devices like flash expect),
+// r[i] holds MISO byte clocked along with w[i].
+//
+// Experimental: 0xc2 command response layout (the request echoed back with MISO bytes
+// in place of MOSI) is assumed, it's not confirmed by vendor documentation or USB captures.
+// It might change once it's verified on hardware.
+//
+// Example:
+//
+//	// Read MCP3008 channel 0. Result is in the last 10 bits of the 3 bytes clocked.
+//	r := make([]byte, 3)
+//	err := c.SPIDuplex([]byte{0x01, 0x80, 0x00}, r)
+//	v := int(r[1]&0x03)<<8 | int(r[2])
+func (c *IO) SPIDuplex(w, r []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.SPIStrictLength && len(w) > 0 && len(r) > 0 && len(w) != len(r) {
+		return ErrSPILength
+	}
+
+	c.activity()
+
+	err := c.withAutoCS(func() error {
+		if err := c.refreshSPI(); err != nil {
+			return err
+		}
+
+		return c.resync(func() error { return c.spiDuplex(w, r) })
+	})
+	c.metrics.done(&c.metrics.spiBytes, max(len(w), len(r)), err)
+
+	return err
+}
+
+// SPITransfer performs full-duplex transfer of w and returns MISO bytes clocked along with it,
+// in a new slice of the same length. It's experimental, see SPIDuplex.
+//
+// Example:
+//
+//	// Read flash JEDEC ID. First byte is clocked during the instruction itself.
+//	r, err := c.SPITransfer([]byte{0x9f, 0xff, 0xff, 0xff})
+//	id := r[1:]
+func (c *IO) SPITransfer(w []byte) ([]byte, error) {
+	r := make([]byte, len(w))
+
+	err := c.SPIDuplex(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (c *IO) spiDuplex(w, r []byte) error {
+	const CmdSPIReadWrite byte = 0xc2
+
+	if err := c.checkSPIPins(); err != nil {
+		return err
+	}
+
+	maxDataLen := c.packetSize() - 5 // Length, CMD and data length go first.
+
+	n := max(len(w), len(r))
+	p := make([]byte, maxPacketLen)
+
+	for pos := 0; pos < n; {
+		dlen := min(n-pos, maxDataLen)
+
+		// len		CMD	dlen	data
+		// 0500		c2	0200	9f ff
+		plen := 3 + dlen
+		p = p[:5+dlen]
+		p[0] = byte(plen & 0xff)
+		p[1] = byte((plen >> 8) & 0xff)
+		p[2] = CmdSPIReadWrite
+		p[3] = byte(dlen & 0xff)
+		p[4] = byte((dlen >> 8) & 0xff)
+
+		for i := 0; i < dlen; i++ {
+			if pos+i < len(w) {
+				p[5+i] = w[pos+i]
+			} else {
+				p[5+i] = 0xff // Default data.
+			}
+		}
+
+		err := c.write(p)
+		if err != nil {
+			return err
+		}
+
+		// Response is assumed to have the same layout, with MISO data.
+		p = p[:5+dlen]
+		_, err = c.read(p)
+		if err != nil {
+			return err
+		}
+
+		err = checkResponse("spi duplex", p[:5], CmdSPIReadWrite, byte(dlen&0xff), byte((dlen>>8)&0xff))
+		if err != nil {
+			return err
+		}
+
+		if pos < len(r) {
+			copy(r[pos:min(pos+dlen, len(r))], p[5:])
+		}
+
+		pos += dlen
+	}
+
+	return nil
+}
+
+// SPIWithMode performs SPI transfer with given mode, restoring the mode set by SetSPI afterwards.
+// It's meant for devices sharing the bus but needing a different mode.
+// CS0 (cs = 0) or CS1 (cs = 1) is asserted for the transfer, -1 asserts CS selected by AutoCS, if any.
+//
+// SetSPI must be called first, ErrSPINotConfigured is returned otherwise.
+//
+// Note: switching the mode costs a configuration round trip (about 1ms) before and after
+// the transfer, none if mode is the current one.
+func (c *IO) SPIWithMode(mode SPIMode, cs int, w, r []byte) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.spiSet {
+		return ErrSPINotConfigured
+	}
+
+	prev := c.spiMode
+	if mode != prev {
+		err = c.setSPI(mode, c.spiClock, c.spiByteOrder)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if rerr := c.setSPI(prev, c.spiClock, c.spiByteOrder); err == nil {
+				err = rerr
+			}
+		}()
+	}
+
+	c.activity()
+
+	transfer := func() error { return c.spi(w, r) }
+	if cs >= 0 {
+		err = c.withCS(cs, transfer)
+	} else {
+		err = c.withAutoCS(transfer)
+	}
+
+	c.metrics.done(&c.metrics.spiBytes, len(w)+len(r), err)
+
+	return err
+}
+
+// SPICommandRead writes cmd, clocks dummy bytes and reads len(r) bytes into r,
+// all within a single CS0 (cs = 0) or CS1 (cs = 1) assertion.
+// CS is driven within the same lock as the transfer, AutoCS is not applied on top of it.
+//
+// Example:
+//
+//	// Flash fast read (0x0b) of 256 bytes from address 0x001000 with 1 dummy byte.
+//	r := make([]byte, 256)
+//	err := c.SPICommandRead(0, []byte{0x0b, 0x00, 0x10, 0x00}, 1, r)
+func (c *IO) SPICommandRead(cs int, cmd []byte, dummy int, r []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.activity()
+
+	err := c.withCS(cs, func() error {
+		if dummy == 0 {
+			return c.spi(cmd, r)
+		}
+
+		// Dummy bytes are clocked with the default data as a part of the read.
+		buf := make([]byte, dummy+len(r))
+		err := c.spi(cmd, buf)
+		if err != nil {
+			return err
+		}
+
+		copy(r, buf[dummy:])
+		return nil
+	})
+
+	c.metrics.done(&c.metrics.spiBytes, len(cmd)+dummy+len(r), err)
+
+	return err
+}
+
+// SPIClockDummy clocks n dummy bytes of the default data (0xff), e.g. 74+ clocks SD card init requires.
+//
+// Pass cs 0 or 1 to assert CS0 or CS1 while clocking, or -1 to leave CS untouched
+// (CS selected by AutoCS is still asserted, like with SPI).
+func (c *IO) SPIClockDummy(cs int, n int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.activity()
+
+	// Reading clocks out the default data.
+	clock := func() error { return c.spi(nil, make([]byte, n)) }
+
+	var err error
+	if cs >= 0 {
+		err = c.withCS(cs, clock)
+	} else {
+		err = c.withAutoCS(clock)
+	}
+
+	c.metrics.done(&c.metrics.spiBytes, n, err)
+
+	return err
+}
+
 // SetCS asserts CS0 pin.
 func (c *IO) SetCS(enable bool) error {
 	return c.setCS(0, enable)
@@ -311,10 +795,72 @@ func (c *IO) SetCS1(enable bool) error {
 	return c.setCS(1, enable)
 }
 
+// DeassertCS deasserts both CS0 and CS1 in a single packet.
+func (c *IO) DeassertCS() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.writeCS([2]byte{0xc0, 0xc0})
+}
+
 func (c *IO) setCS(cs int, enable bool) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.driveCS(cs, enable)
+}
+
+// driveCS asserts or deasserts given CS line, ErrInvalidCS is returned for other lines than CS0 and CS1.
+func (c *IO) driveCS(cs int, enable bool) error {
+	if err := checkCS(cs); err != nil {
+		return err
+	}
+
+	if err := c.checkPinRole([2]Pin{GPIO2, GPIO5}[cs], [2]PinRole{PinRoleCS0, PinRoleCS1}[cs]); err != nil {
+		return err
+	}
+
+	var st [2]byte
+	if enable {
+		st[cs] = 0x80
+	} else {
+		st[cs] = 0xc0
+	}
+
+	return c.writeCS(st)
+}
+
+// checkCS returns ErrInvalidCS for CS lines other than CS0 (0) and CS1 (1).
+func checkCS(cs int) error {
+	if cs < 0 || cs > 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidCS, cs)
+	}
+
+	return nil
+}
+
+// withCS runs fn with CS0 (cs = 0) or CS1 (cs = 1) asserted, -1 leaves CS untouched.
+// CS is deasserted even if fn fails.
+func (c *IO) withCS(cs int, fn func() error) error {
+	if cs < 0 {
+		return fn()
+	}
+
+	err := c.driveCS(cs, true)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if cerr := c.driveCS(cs, false); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// writeCS sends CS control packet. Zero state leaves CS untouched.
+func (c *IO) writeCS(st [2]byte) error {
 	const CmdSPICS byte = 0xc1
 
 	p := []byte{
@@ -325,14 +871,10 @@ func (c *IO) setCS(cs int, enable bool) error {
 		0x00, 0x00, 0x00, 0x00,
 	}
 
-	pos := 5 + 5*cs
-
-	if enable {
-		p[pos] = 0x80
-	} else {
-		p[pos] = 0xc0
-	}
+	// 0x80 - assert, 0xc0 - deassert.
+	p[5] = st[0]
+	p[10] = st[1]
 
-	_, err := c.Dev.Write(p)
+	err := c.write(p)
 	return err
 }
@@ -0,0 +1,687 @@
+package ch347
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSPICommandRead(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d, AutoCS: AutoCS1}
+
+	r := make([]byte, 600)
+	if err := c.SPICommandRead(0, []byte{0x0b, 0, 0x10, 0}, 1, r); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, b := range r {
+		if b != byte(i+1) {
+			t.Fatalf("r[%d] = %d, dummy byte not skipped", i, b)
+		}
+	}
+
+	want := []string{"0+", "spi", "spi", "0-"}
+	if ops := csOps(d.written()); !reflect.DeepEqual(ops, want) {
+		t.Fatalf("got %v, want %v", ops, want)
+	}
+}
+
+func TestSPIClockDummy(t *testing.T) {
+	for _, tc := range []struct {
+		cs   int
+		auto AutoCS
+		want []string
+	}{
+		{cs: -1, want: []string{"spi"}},
+		{cs: -1, auto: AutoCS1, want: []string{"1+", "spi", "1-"}},
+		{cs: 0, auto: AutoCS1, want: []string{"0+", "spi", "0-"}},
+	} {
+		d := &mockDev{respond: spiResponder}
+		c := &IO{Dev: d, AutoCS: tc.auto}
+
+		if err := c.SPIClockDummy(tc.cs, 10); err != nil {
+			t.Fatal(err)
+		}
+
+		if ops := csOps(d.written()); !reflect.DeepEqual(ops, tc.want) {
+			t.Fatalf("cs %d, AutoCS %d: got %v, want %v", tc.cs, tc.auto, ops, tc.want)
+		}
+	}
+}
+
+func TestSPIWithModeAutoCS(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d}
+
+	if err := c.SetSPI(SPIMode0, SPIClock0, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	c.AutoCS = AutoCS0
+	d.writes = nil
+
+	if err := c.SPIWithMode(SPIMode0, -1, []byte{0x9f}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"0+", "spi", "0-"}
+	if ops := csOps(d.written()); !reflect.DeepEqual(ops, want) {
+		t.Fatalf("got %v, want %v", ops, want)
+	}
+}
+
+func TestSPIDuplexLength(t *testing.T) {
+	w := []byte{1, 2, 3, 4}
+
+	for _, tc := range []struct {
+		name   string
+		strict bool
+		r      []byte
+		want   []byte
+		err    error
+	}{
+		{name: "equal", r: make([]byte, 4), want: []byte{1, 2, 3, 4}},
+		{name: "shorter r", r: make([]byte, 2), want: []byte{1, 2}},
+		{name: "longer r", r: make([]byte, 6), want: []byte{1, 2, 3, 4, 0xff, 0xff}},
+		{name: "strict equal", strict: true, r: make([]byte, 4), want: []byte{1, 2, 3, 4}},
+		{name: "strict shorter r", strict: true, r: make([]byte, 2), err: ErrSPILength},
+		{name: "strict longer r", strict: true, r: make([]byte, 6), err: ErrSPILength},
+		{name: "strict write only", strict: true},
+	} {
+		d := &mockDev{respond: spiResponder}
+		c := &IO{Dev: d, SPIStrictLength: tc.strict}
+
+		err := c.SPIDuplex(w, tc.r)
+		if err != tc.err {
+			t.Fatalf("%s: got %v, want %v", tc.name, err, tc.err)
+		}
+
+		if err != nil {
+			if len(d.written()) != 0 {
+				t.Fatalf("%s: transfer started", tc.name)
+			}
+
+			continue
+		}
+
+		if tc.want != nil && !reflect.DeepEqual(tc.r, tc.want) {
+			t.Fatalf("%s: got %x, want %x", tc.name, tc.r, tc.want)
+		}
+	}
+}
+
+func TestSPIRefreshAfterI2C(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d, DriveCSIdle: true}
+
+	if err := c.SetSPI(SPIMode3, SPIClock2, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SetI2C(I2CMode1); err != nil {
+		t.Fatal(err)
+	}
+
+	d.writes = nil
+
+	for i := 0; i < 2; i++ {
+		if err := c.SPI([]byte{0x9f}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var cmds []byte
+	for _, p := range d.written() {
+		cmds = append(cmds, p[2])
+	}
+
+	// Configuration is re-applied once, without driving CS.
+	if want := []byte{0xc0, 0xc4, 0xc4}; !reflect.DeepEqual(cmds, want) {
+		t.Fatalf("got % x, want % x", cmds, want)
+	}
+
+	if mode, clock, _, _ := c.GetSPI(); mode != SPIMode3 || clock != SPIClock2 {
+		t.Fatalf("got mode %d, clock %d", mode, clock)
+	}
+}
+
+func TestSPIResponseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		w, r []byte
+		resp []byte
+	}{
+		// Confirmation length right, command wrong.
+		{name: "write", w: []byte{0x9f}, resp: []byte{3, 0, 0xc3, 1, 0}},
+		{name: "read command", r: make([]byte, 1), resp: []byte{4, 0, 0xc4, 1, 0, 0xaa}},
+		{name: "read length", r: make([]byte, 1), resp: []byte{4, 0, 0xc3, 2, 0, 0xaa}},
+	} {
+		d := &mockDev{}
+		d.queue(tc.resp)
+		c := &IO{Dev: d}
+
+		err := c.SPI(tc.w, tc.r)
+
+		var derr *DeviceError
+		if !errors.As(err, &derr) || !errors.Is(err, ErrInvalidResponse) {
+			t.Fatalf("%s: got %v, want DeviceError", tc.name, err)
+		}
+	}
+}
+
+// spiWriteSim checks SPI write (0xc4) framing and collects the data written.
+type spiWriteSim struct {
+	t       *testing.T
+	data    []byte
+	opLeft  int // Data bytes left in the current write operation.
+	pending int // Packets not confirmed yet.
+	maxLen  int // Longest packet written.
+}
+
+func (d *spiWriteSim) SendFeatureReport(p []byte) (int, error) { return len(p), nil }
+
+func (d *spiWriteSim) Write(p []byte) (int, error) {
+	if len(p) > maxPacketLen {
+		d.t.Fatalf("packet is %d bytes long", len(p))
+	}
+
+	d.maxLen = max(d.maxLen, len(p))
+
+	if plen := int(p[0]) | int(p[1])<<8; plen != len(p)-2 {
+		d.t.Fatalf("packet length %d, sent %d bytes", plen, len(p)-2)
+	}
+
+	data := p[2:]
+	if d.opLeft == 0 {
+		if data[0] != 0xc4 {
+			d.t.Fatalf("expected write operation, got %#x", data[0])
+		}
+
+		d.opLeft = int(data[1]) | int(data[2])<<8
+		data = data[3:]
+	}
+
+	if len(data) > d.opLeft {
+		d.t.Fatalf("%d bytes past write operation", len(data)-d.opLeft)
+	}
+
+	d.opLeft -= len(data)
+	d.data = append(d.data, data...)
+	d.pending++
+
+	return len(p), nil
+}
+
+func (d *spiWriteSim) Read(p []byte) (int, error) {
+	if d.pending == 0 {
+		return 0, ErrTimeout
+	}
+
+	d.pending--
+	return copy(p, []byte{3, 0, 0xc4, 1, 0}), nil
+}
+
+func TestSPIWrite(t *testing.T) {
+	// Packet and operation boundaries: 504 bytes fill the first packet exactly.
+	for _, n := range []int{1, 503, 504, 505, 1012, 1013, 1014, 31750, 31751, 70000} {
+		d := &spiWriteSim{t: t}
+		c := &IO{Dev: d}
+
+		w := make([]byte, n)
+		for i := range w {
+			w[i] = byte(i * 7)
+		}
+
+		if err := c.SPI(w, nil); err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+
+		if !bytes.Equal(d.data, w) || d.opLeft != 0 || d.pending != 0 {
+			t.Fatalf("%d bytes: %d written, %d left in operation, %d packets unconfirmed",
+				n, len(d.data), d.opLeft, d.pending)
+		}
+	}
+}
+
+func TestSPIDuplexFraming(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d}
+
+	if _, err := c.SPITransfer(make([]byte, 1200)); err != nil {
+		t.Fatal(err)
+	}
+
+	var lens []int
+	for _, p := range d.written() {
+		if p[2] != 0xc2 {
+			t.Fatalf("got command %#x, want 0xc2", p[2])
+		}
+
+		dlen := int(p[3]) | int(p[4])<<8
+		if plen := int(p[0]) | int(p[1])<<8; plen != 3+dlen || len(p) != 5+dlen {
+			t.Fatalf("packet length %d, data length %d, sent %d bytes", plen, dlen, len(p))
+		}
+
+		lens = append(lens, dlen)
+	}
+
+	if want := []int{507, 507, 186}; !reflect.DeepEqual(lens, want) {
+		t.Fatalf("got chunks %v, want %v", lens, want)
+	}
+
+	d.writes = nil
+	if err := c.SPIDuplex([]byte{0x9f, 0x01}, make([]byte, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{0x06, 0x00, 0xc2, 0x03, 0x00, 0x9f, 0x01, 0xff}
+	if writes := d.written(); len(writes) != 1 || !bytes.Equal(writes[0], want) {
+		t.Fatalf("got % x, want % x", writes, want)
+	}
+}
+
+func TestSetCSPolarity(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d}
+
+	// Not configured yet, applied by SetSPI.
+	if err := c.SetCSPolarity(1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.written()) != 0 {
+		t.Fatal("config sent before SetSPI")
+	}
+
+	if err := c.SetSPI(SPIMode0, SPIClock0, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SetCSPolarity(0, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SetCSPolarity(1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var pol []byte
+	for _, w := range d.written() {
+		if w[2] == 0xc0 {
+			pol = append(pol, w[26]) // "25 byte - CS Polarity" of setSPIOnce.
+		}
+	}
+
+	if want := []byte{0x40, 0xc0, 0x80}; !bytes.Equal(pol, want) {
+		t.Fatalf("CS polarity bytes % x, want % x", pol, want)
+	}
+
+	for _, cs := range []int{-1, 2} {
+		if err := c.SetCSPolarity(cs, true); !errors.Is(err, ErrInvalidCS) {
+			t.Fatalf("cs %d: got %v, want ErrInvalidCS", cs, err)
+		}
+	}
+}
+
+func TestSPIInvalidCS(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d, AutoCS: AutoCS1 + 1}
+
+	if err := c.SetSPI(SPIMode0, SPIClock0, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	n := len(d.written())
+
+	if err := c.SPI([]byte{1}, nil); !errors.Is(err, ErrInvalidCS) {
+		t.Fatalf("SPI: got %v, want ErrInvalidCS", err)
+	}
+
+	if err := c.SPICommandRead(2, []byte{0x9f}, 0, make([]byte, 3)); !errors.Is(err, ErrInvalidCS) {
+		t.Fatalf("SPICommandRead: got %v, want ErrInvalidCS", err)
+	}
+
+	if err := c.SPIClockDummy(2, 10); !errors.Is(err, ErrInvalidCS) {
+		t.Fatalf("SPIClockDummy: got %v, want ErrInvalidCS", err)
+	}
+
+	if ops := csOps(d.written()[n:]); len(ops) != 0 {
+		t.Fatalf("packets sent for invalid cs: %v", ops)
+	}
+
+	pl := NewSPIPlayer(c, 2)
+	pl.Submit([]byte{1, 2, 3})
+
+	if err := pl.Close(); !errors.Is(err, ErrInvalidCS) {
+		t.Fatalf("SPIPlayer: got %v, want ErrInvalidCS", err)
+	}
+}
+
+func TestAutoCS(t *testing.T) {
+	var cfg [][]byte
+
+	for _, auto := range []AutoCS{AutoCSOff, AutoCS1} {
+		d := &mockDev{respond: spiResponder}
+		c := &IO{Dev: d, AutoCS: auto}
+
+		if err := c.SetSPI(SPIMode0, SPIClock0, SPIByteOrderMSB); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg = append(cfg, d.written()[0])
+		d.writes = nil
+
+		if err := c.SPI([]byte{0x9f}, make([]byte, 3)); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"spi", "spi"}
+		if auto == AutoCS1 {
+			want = []string{"1+", "spi", "spi", "1-"}
+		}
+
+		if ops := csOps(d.written()); !reflect.DeepEqual(ops, want) {
+			t.Fatalf("AutoCS %d: got %v, want %v", auto, ops, want)
+		}
+	}
+
+	// Software sequenced, configuration is the same.
+	if !bytes.Equal(cfg[0], cfg[1]) {
+		t.Fatalf("config packet changed:\n% x\n% x", cfg[0], cfg[1])
+	}
+}
+
+// BenchmarkSPIFlashRead compares a flash read (0x03) with SetCS calls around it and with AutoCS.
+// AutoCS is software sequenced, so both send the same packets.
+func BenchmarkSPIFlashRead(b *testing.B) {
+	for _, auto := range []bool{false, true} {
+		name := "SetCS"
+		if auto {
+			name = "AutoCS"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			d := &mockDev{respond: spiResponder}
+			c := &IO{Dev: d}
+
+			if err := c.SetSPI(SPIMode0, SPIClock1, SPIByteOrderMSB); err != nil {
+				b.Fatal(err)
+			}
+
+			if auto {
+				c.AutoCS = AutoCS0
+			}
+
+			w := []byte{0x03, 0x00, 0x10, 0x00}
+			r := make([]byte, 256)
+
+			d.writes = nil
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				var err error
+				if auto {
+					err = c.SPI(w, r)
+				} else {
+					c.SetCS(true)
+					err = c.SPI(w, r)
+					c.SetCS(false)
+				}
+
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(len(d.written()))/float64(b.N), "packets/op")
+		})
+	}
+}
+
+func TestProbeSPIClocks(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d}
+
+	if err := c.SetSPI(SPIMode3, SPIClock2, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	fail := errors.New("no response")
+
+	clocks, err := c.ProbeSPIClocks(func(cl SPIClock) (bool, error) {
+		switch {
+		case cl == SPIClock3:
+			return false, fail
+		case cl == SPIClock5:
+			return true, fail
+		}
+
+		return cl >= SPIClock1, nil
+	})
+
+	if !errors.Is(err, fail) {
+		t.Fatalf("got %v, want fn errors", err)
+	}
+
+	if want := []SPIClock{SPIClock1, SPIClock2, SPIClock4, SPIClock6, SPIClock7}; !reflect.DeepEqual(clocks, want) {
+		t.Fatalf("got %v, want %v", clocks, want)
+	}
+
+	if mode, clock, _, _ := c.GetSPI(); mode != SPIMode3 || clock != SPIClock2 {
+		t.Fatalf("configuration not restored: mode %d, clock %d", mode, clock)
+	}
+}
+
+func TestResetSPI(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d}
+
+	if err := c.SetSPI(SPIMode0, SPIClock0, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write confirmations left by an interrupted transfer.
+	d.queue([]byte{3, 0, 0xc4, 1, 0}, []byte{3, 0, 0xc4, 1, 0})
+
+	r := make([]byte, 4)
+	if err := c.SPI(nil, r); !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("out of sync read: got %v, want ErrInvalidResponse", err)
+	}
+
+	d.writes = nil
+	if err := c.ResetSPI(); err != nil {
+		t.Fatal(err)
+	}
+
+	if writes := d.written(); len(writes) != 1 || writes[0][2] != 0xc0 {
+		t.Fatalf("got % x, want config re-applied", writes)
+	}
+
+	if err := c.SPI(nil, r); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(r, []byte{0, 1, 2, 3}) {
+		t.Fatalf("got % x", r)
+	}
+}
+
+// csWriteSim is spiWriteSim also accepting CS packets, logging them like csOps along with "op"
+// for every write operation started.
+type csWriteSim struct {
+	spiWriteSim
+	ops []string
+}
+
+func (d *csWriteSim) Write(p []byte) (int, error) {
+	if d.opLeft == 0 && p[2] == 0xc1 {
+		d.ops = append(d.ops, csOps([][]byte{p})...)
+		return len(p), nil
+	}
+
+	if d.opLeft == 0 {
+		d.ops = append(d.ops, "op")
+	}
+
+	return d.spiWriteSim.Write(p)
+}
+
+func TestSPIWriteCSAcrossOperations(t *testing.T) {
+	d := &csWriteSim{spiWriteSim: spiWriteSim{t: t}}
+	c := &IO{Dev: d}
+
+	// More than two write operations long.
+	w := make([]byte, 70000)
+	for i := range w {
+		w[i] = byte(i * 3)
+	}
+
+	if err := c.SetCS(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SPI(w, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SetCS(false); err != nil {
+		t.Fatal(err)
+	}
+
+	// CS is not touched between operations.
+	want := []string{"0+", "op", "op", "op", "0-"}
+	if !reflect.DeepEqual(d.ops, want) {
+		t.Fatalf("got %v, want %v", d.ops, want)
+	}
+
+	if !bytes.Equal(d.data, w) {
+		t.Fatal("written data mismatch")
+	}
+}
+
+func TestNearestSPIClock(t *testing.T) {
+	for _, tc := range []struct {
+		hz   uint32
+		want SPIClock
+	}{
+		{100_000_000, SPIClock0}, // Clamped.
+		{60_000_000, SPIClock0},
+		{59_999_999, SPIClock1},
+		{30_000_000, SPIClock1},
+		{20_000_000, SPIClock2},
+		{1_000_000, SPIClock6},
+		{937_500, SPIClock6},
+		{468_750, SPIClock7},
+		{100_000, SPIClock7}, // Clamped, actual clock is faster.
+		{0, SPIClock7},
+	} {
+		if clock := NearestSPIClock(tc.hz); clock != tc.want {
+			t.Errorf("%d Hz: got %d, want %d", tc.hz, clock, tc.want)
+		}
+	}
+}
+
+func TestSetSPIHz(t *testing.T) {
+	c := &IO{Dev: &mockDev{respond: spiResponder}}
+
+	clock, err := c.SetSPIHz(SPIMode3, 20_000_000, SPIByteOrderMSB)
+	if err != nil || clock != SPIClock2 {
+		t.Fatalf("got %d, %v", clock, err)
+	}
+
+	if mode, clock, _, ok := c.GetSPI(); !ok || mode != SPIMode3 || clock != SPIClock2 {
+		t.Fatalf("configured mode %d, clock %d", mode, clock)
+	}
+
+	// Too slow request reports the clock actually set.
+	if clock, err := c.SetSPIHz(SPIMode0, 1000, SPIByteOrderMSB); err != nil || clock != SPIClock7 {
+		t.Fatalf("got %d, %v", clock, err)
+	}
+}
+
+func TestSPIClockHz(t *testing.T) {
+	want := []float64{60e6, 30e6, 15e6, 7.5e6, 3.75e6, 1.875e6, 937.5e3, 468.75e3}
+	for clock, hz := range want {
+		if got := SPIClock(clock).Hz(); got != hz {
+			t.Errorf("SPIClock%d: got %v, want %v", clock, got, hz)
+		}
+	}
+
+	c := &IO{Dev: &mockDev{respond: spiResponder}}
+	if _, ok := c.GetSPIHz(); ok {
+		t.Fatal("frequency of not configured SPI")
+	}
+
+	if err := c.SetSPI(SPIMode0, SPIClock3, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	if hz, ok := c.GetSPIHz(); !ok || hz != 7.5e6 {
+		t.Fatalf("got %v, %v", hz, ok)
+	}
+}
+
+func TestSetSPIKeepsCSPolarity(t *testing.T) {
+	config := func(c *IO, d *mockDev) []byte {
+		d.writes = nil
+		if err := c.SetSPI(SPIMode3, SPIClock5, SPIByteOrderLSB); err != nil {
+			t.Fatal(err)
+		}
+
+		return d.written()[0]
+	}
+
+	fresh := &mockDev{respond: spiResponder}
+	want := config(&IO{Dev: fresh}, fresh)
+
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d}
+
+	if err := c.SetSPI(SPIMode0, SPIClock0, SPIByteOrderMSB); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SetCSPolarity(0, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mode, clock and byte order are replaced, polarity is kept, the rest is fixed.
+	got := config(c, d)
+	if got[26] != 0x80 {
+		t.Fatalf("CS polarity byte 0x%02x, want 0x80", got[26])
+	}
+
+	got[26] = want[26]
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got % x, want % x", got, want)
+	}
+}
+
+func TestSPIReadOnly(t *testing.T) {
+	d := &mockDev{respond: spiResponder}
+	c := &IO{Dev: d}
+
+	// Spans two responses.
+	r := make([]byte, 600)
+	if err := c.SPI(nil, r); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, b := range r {
+		if b != byte(i) {
+			t.Fatalf("r[%d] = %d", i, b)
+		}
+	}
+
+	// A single read request, nothing written.
+	writes := d.written()
+	if len(writes) != 1 || writes[0][2] != 0xc3 {
+		t.Fatalf("got % x", writes)
+	}
+
+	if rlen := int(writes[0][5]) | int(writes[0][6])<<8 | int(writes[0][7])<<16 | int(writes[0][8])<<24; rlen != len(r) {
+		t.Fatalf("read length %d", rlen)
+	}
+}
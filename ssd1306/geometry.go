@@ -0,0 +1,54 @@
+package ssd1306
+
+import "fmt"
+
+// geometry holds per module size settings.
+type geometry struct {
+	comPins   byte // COM pins hardware configuration.
+	colOffset int  // First RAM column wired to the panel.
+}
+
+// Supported module sizes.
+var geometries = map[[2]int]geometry{
+	{128, 64}: {comPins: 0x12},
+	{128, 32}: {comPins: 0x02},
+	{96, 16}:  {comPins: 0x02},
+	{64, 48}:  {comPins: 0x12, colOffset: 32}, // Panel is centered in 128 columns RAM.
+}
+
+func lookupGeometry(width, height int) (geometry, error) {
+	g, ok := geometries[[2]int{width, height}]
+	if !ok {
+		return g, fmt.Errorf("unsupported display size %dx%d", width, height)
+	}
+
+	return g, nil
+}
+
+// InitSequence returns initialization commands for display of given size.
+// Supported sizes: 128x64, 128x32, 96x16 and 64x48.
+func InitSequence(width, height int) ([]byte, error) {
+	g, err := lookupGeometry(width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte{
+		0xae,       // SSD1306_CMD_DISPLAY_OFF
+		0xd5, 0x80, // SSD1306_CMD_SET_DISPLAY_CLK_DIV
+		0xa8, byte(height - 1), // SSD1306_CMD_SET_MUX_RATIO
+		0xd3, 0x00, // SSD1306_CMD_SET_DISPLAY_OFFSET
+		0x40,       // SSD1306_CMD_SET_DISPLAY_START_LINE
+		0x8d, 0x14, // SSD1306_CMD_SET_CHARGE_PUMP
+		0x20, 0x00, // SSD1306_CMD_SET_MEMORY_ADDR_MODE // SSD1306_CMD_SET_HORI_ADDR_MODE
+		0xa1,            // SSD1306_CMD_SET_SEGMENT_REMAP_1
+		0xc8,            // SSD1306_CMD_SET_COM_SCAN_MODE
+		0xda, g.comPins, // SSD1306_CMD_SET_COM_PIN_MAP
+		0x81, 0xff, // SSD1306_CMD_SET_CONTRAST
+		0xd9, 0xf1, // SSD1306_CMD_SET_PRECHARGE
+		0xd8, 0x40, // SSD1306_CMD_SET_VCOMH_DESELCT
+		0xa4, // SSD1306_CMD_DISPLAY_RAM
+		0xa6, // SSD1306_CMD_DISPLAY_NORMAL
+		0xaf, // SSD1306_CMD_DISPLAY_ON
+	}, nil
+}
@@ -0,0 +1,52 @@
+package ssd1306
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/serfreeman1337/go-ch347"
+)
+
+func TestInitSequence(t *testing.T) {
+	for _, tc := range []struct {
+		width, height int
+		mux, comPins  byte
+		cols, pages   []byte // Column and page ranges of a full flush.
+	}{
+		{128, 64, 0x3f, 0x12, []byte{0, 127}, []byte{0, 7}},
+		{128, 32, 0x1f, 0x02, []byte{0, 127}, []byte{0, 3}},
+		{96, 16, 0x0f, 0x02, []byte{0, 95}, []byte{0, 1}},
+		{64, 48, 0x2f, 0x12, []byte{32, 95}, []byte{0, 5}},
+	} {
+		seq, err := InitSequence(tc.width, tc.height)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i := bytes.IndexByte(seq, 0xa8); i < 0 || seq[i+1] != tc.mux {
+			t.Fatalf("%dx%d: multiplex ratio in % x, want 0x%02x", tc.width, tc.height, seq, tc.mux)
+		}
+
+		if i := bytes.IndexByte(seq, 0xda); i < 0 || seq[i+1] != tc.comPins {
+			t.Fatalf("%dx%d: COM pins in % x, want 0x%02x", tc.width, tc.height, seq, tc.comPins)
+		}
+
+		sim := &oledSim{t: t}
+		if _, err := New(&ch347.IO{Dev: sim}, Config{DC: ch347.GPIO1, RST: ch347.GPIO5, Width: tc.width, Height: tc.height, SkipReset: true}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []byte{0x21, tc.cols[0], tc.cols[1], 0x22, tc.pages[0], tc.pages[1]}
+		if x := sim.xfers[1]; !bytes.Equal(x.p, want) {
+			t.Fatalf("%dx%d: got % x, want % x", tc.width, tc.height, x.p, want)
+		}
+
+		if x := sim.xfers[2]; len(x.p) != tc.width*tc.height/8 {
+			t.Fatalf("%dx%d: %d bytes framebuffer sent", tc.width, tc.height, len(x.p))
+		}
+	}
+
+	if _, err := InitSequence(128, 128); err == nil {
+		t.Fatal("128x128 accepted")
+	}
+}
@@ -0,0 +1,282 @@
+// Package ssd1306 drives SSD1306 SPI OLED displays over CH347.
+//
+// Display connection as follows:
+//
+//	  CH347       SSD1306 SPI OLED display
+//	- 3.3V    ->  VCC
+//	- GND     ->  GND
+//	- SCK     ->  D0
+//	- MOSI    ->  D1
+//	- MISO    ->  DC
+//	- CS0     ->  CS
+//	- CS1     ->  RES
+//
+// MISO and CS1 are used as GPIO1 and GPIO5 respectively for the DC and RES lines,
+// any other free pins work too.
+//
+// Several displays can share the bus, DC and RES lines, each one with its own CS line:
+// CS0, CS1 or a free GPIO pin. Drive them from a single goroutine.
+package ssd1306
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/serfreeman1337/go-ch347"
+)
+
+// Config describes display connection.
+type Config struct {
+	DC  ch347.Pin  // Data/command line.
+	RST ch347.Pin  // Reset line.
+	CS  CSSelector // Defaults to HardwareCS(0).
+
+	// Display size, defaults to 128x64. See InitSequence for supported sizes.
+	Width, Height int
+
+	// SkipReset skips RST sequence, for displays sharing RST line with an already initialized one.
+	SkipReset bool
+}
+
+// CSSelector asserts (on = true) or deasserts display CS line.
+type CSSelector func(c *ch347.IO, on bool) error
+
+// HardwareCS selects display with CS0 (cs = 0) or CS1 (cs = 1).
+func HardwareCS(cs int) CSSelector {
+	return func(c *ch347.IO, on bool) error {
+		if cs == 1 {
+			return c.SetCS1(on)
+		}
+
+		return c.SetCS(on)
+	}
+}
+
+// GPIOCS selects display with active low CS line driven by a GPIO pin.
+//
+// Example:
+//
+//	// Third display on GPIO6.
+//	d3, err := ssd1306.New(c, ssd1306.Config{DC: ch347.GPIO1, RST: ch347.GPIO5, CS: ssd1306.GPIOCS(ch347.GPIO6), SkipReset: true})
+func GPIOCS(pin ch347.Pin) CSSelector {
+	return func(c *ch347.IO, on bool) error {
+		return c.WritePin(pin, true, !on)
+	}
+}
+
+// Display is SSD1306 display with a framebuffer.
+//
+// Drawing methods change the framebuffer only, call Flush or FlushPage to show it.
+type Display struct {
+	c   *ch347.IO
+	cfg Config
+	buf []byte
+	geo geometry
+
+	Width, Height int
+}
+
+// New resets and initializes display.
+func New(c *ch347.IO, cfg Config) (*Display, error) {
+	d := &Display{
+		c:      c,
+		cfg:    cfg,
+		Width:  cfg.Width,
+		Height: cfg.Height,
+	}
+
+	if d.Width == 0 && d.Height == 0 {
+		d.Width, d.Height = 128, 64
+	}
+
+	var err error
+	d.geo, err = lookupGeometry(d.Width, d.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	d.buf = make([]byte, d.Width*d.pages())
+
+	if d.cfg.CS == nil {
+		d.cfg.CS = HardwareCS(0)
+	}
+
+	// Keep CS inactive, GPIO CS might be low after power up.
+	err = d.cfg.CS(c, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.SkipReset {
+		err = d.reset()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seq, err := InitSequence(d.Width, d.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.command(seq...)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, d.Flush()
+}
+
+// reset triggers RST sequence.
+func (d *Display) reset() error {
+	for _, st := range []struct {
+		level bool
+		delay time.Duration
+	}{{true, 1 * time.Millisecond}, {false, 10 * time.Millisecond}, {true, 0}} {
+		err := d.c.WritePin(d.cfg.RST, true, st.level)
+		if err != nil {
+			return err
+		}
+
+		time.Sleep(st.delay)
+	}
+
+	return nil
+}
+
+// Clear clears the framebuffer.
+func (d *Display) Clear() {
+	clear(d.buf)
+}
+
+// SetPixel sets or clears a pixel. Pixels out of the display are ignored.
+func (d *Display) SetPixel(x, y int, on bool) {
+	if x < 0 || x >= d.Width || y < 0 || y >= d.Height {
+		return
+	}
+
+	if on {
+		d.buf[(y/8)*d.Width+x] |= 1 << (y % 8)
+	} else {
+		d.buf[(y/8)*d.Width+x] &^= 1 << (y % 8)
+	}
+}
+
+// Text draws s with 5x7 font at column x of page (8 pixels high row).
+// Text out of the display is clipped, so x can be negative.
+func (d *Display) Text(x, page int, s string) {
+	if page < 0 || page >= d.pages() {
+		return
+	}
+
+	row := d.buf[page*d.Width : (page+1)*d.Width]
+
+	for i := 0; i < len(s); i++ {
+		g := glyph(s[i])
+
+		for col, bits := range g {
+			if cx := x + i*charWidth + col; cx >= 0 && cx < d.Width {
+				row[cx] = bits
+			}
+		}
+	}
+}
+
+// TextWidth returns width of s in pixels.
+func TextWidth(s string) int {
+	return len(s) * charWidth
+}
+
+// Flush sends the whole framebuffer to the display.
+func (d *Display) Flush() error {
+	return d.flush(0, d.pages()-1)
+}
+
+// FlushPage sends a single page of the framebuffer, which is 8 times less data than Flush.
+func (d *Display) FlushPage(page int) error {
+	if page < 0 || page >= d.pages() {
+		return fmt.Errorf("invalid page %d", page)
+	}
+
+	return d.flush(page, page)
+}
+
+// ScrollText scrolls s from right to left across the page, moving it by a pixel every speed,
+// until ctx is cancelled. Only the page is updated on every step.
+func (d *Display) ScrollText(ctx context.Context, page int, s string, speed time.Duration) error {
+	if page < 0 || page >= d.pages() {
+		return fmt.Errorf("invalid page %d", page)
+	}
+
+	t := time.NewTicker(speed)
+	defer t.Stop()
+
+	row := d.buf[page*d.Width : (page+1)*d.Width]
+
+	for step := 0; ; step++ {
+		clear(row)
+		d.Text(scrollX(step, d.Width, TextWidth(s)), page, s)
+
+		err := d.FlushPage(page)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// scrollX returns text position at given scroll step. Text enters from the right edge
+// and starts over once it has fully left from the left edge.
+func scrollX(step, width, textWidth int) int {
+	return width - step%(width+textWidth)
+}
+
+func (d *Display) pages() int {
+	return d.Height / 8
+}
+
+// flush sends pages from first to last.
+func (d *Display) flush(first, last int) error {
+	err := d.command(
+		0x21, byte(d.geo.colOffset), byte(d.geo.colOffset+d.Width-1), // SSD1306_CMD_SET_COLUMN_RANGE
+		0x22, byte(first), byte(last), // SSD1306_CMD_SET_PAGE_RANGE
+	)
+	if err != nil {
+		return err
+	}
+
+	return d.data(d.buf[first*d.Width : (last+1)*d.Width])
+}
+
+func (d *Display) command(cmd ...byte) error {
+	return d.send(0, cmd)
+}
+
+func (d *Display) data(p []byte) error {
+	return d.send(1, p)
+}
+
+// send sets DC line and sends p with display CS asserted.
+// DC is set every time, as it can be shared with other displays.
+func (d *Display) send(dc int, p []byte) error {
+	err := d.c.WritePin(d.cfg.DC, true, dc == 1)
+	if err != nil {
+		return err
+	}
+
+	err = d.cfg.CS(d.c, true)
+	if err != nil {
+		return err
+	}
+
+	err = d.c.SPI(p, nil)
+	d.cfg.CS(d.c, false)
+
+	return err
+}
@@ -0,0 +1,226 @@
+package ssd1306
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/serfreeman1337/go-ch347"
+)
+
+// transfer is SPI data sent to the display with CS asserted.
+type transfer struct {
+	dc bool // Data, not command.
+	p  []byte
+}
+
+// oledSim is CH347 interface with SSD1306 on the SPI bus, DC and RST on GPIO pins.
+type oledSim struct {
+	t      *testing.T
+	pins   [8]byte // GPIO status bytes.
+	resps  [][]byte
+	opLeft int // Data bytes left in the current SPI write operation.
+
+	cs     bool
+	resets int // RST low pulses.
+	xfers  []transfer
+}
+
+func (d *oledSim) SendFeatureReport(p []byte) (int, error) { return len(p), nil }
+
+func (d *oledSim) Write(p []byte) (int, error) {
+	data := p[2:]
+
+	switch {
+	case d.opLeft > 0: // Write operation continues.
+	case data[0] == 0xcc:
+		d.gpio(data[3:11])
+		return len(p), nil
+	case data[0] == 0xc1:
+		switch data[3] { // CS0.
+		case 0x80:
+			d.cs = true
+			d.xfers = append(d.xfers, transfer{dc: d.pins[ch347.GPIO1] == 0xc0})
+		case 0xc0:
+			d.cs = false
+		}
+
+		return len(p), nil
+	case data[0] == 0xc4:
+		d.opLeft = int(data[1]) | int(data[2])<<8
+		data = data[3:]
+	default:
+		d.t.Fatalf("unexpected packet % x", p)
+	}
+
+	if !d.cs {
+		d.t.Fatal("SPI write without CS")
+	}
+
+	x := &d.xfers[len(d.xfers)-1]
+	x.p = append(x.p, data...)
+
+	d.opLeft -= len(data)
+	d.resps = append(d.resps, []byte{0x03, 0x00, 0xc4, 0x01, 0x00})
+
+	return len(p), nil
+}
+
+// gpio applies pin set bytes and responds with pins status.
+func (d *oledSim) gpio(set []byte) {
+	for i, b := range set {
+		switch b {
+		case 0xf8:
+			d.pins[i] = 0xc0
+		case 0xf0:
+			if i == int(ch347.GPIO5) && d.pins[i] != 0x80 {
+				d.resets++
+			}
+
+			d.pins[i] = 0x80
+		}
+	}
+
+	d.resps = append(d.resps, append([]byte{0x0b, 0x00, 0xcc, 0x08, 0x00}, d.pins[:]...))
+}
+
+func (d *oledSim) Read(p []byte) (int, error) {
+	if len(d.resps) == 0 {
+		d.t.Fatal("no response")
+	}
+
+	r := d.resps[0]
+	d.resps = d.resps[1:]
+
+	return copy(p, r), nil
+}
+
+func TestDisplay(t *testing.T) {
+	sim := &oledSim{t: t}
+	c := &ch347.IO{Dev: sim}
+
+	d, err := New(c, Config{DC: ch347.GPIO1, RST: ch347.GPIO5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seq, _ := InitSequence(128, 64)
+
+	// Init sequence, then the whole (blank) framebuffer.
+	want := []transfer{
+		{false, seq},
+		{false, []byte{0x21, 0x00, 0x7f, 0x22, 0x00, 0x07}},
+		{true, make([]byte, 128*8)},
+	}
+
+	if sim.resets != 1 || len(sim.xfers) != len(want) {
+		t.Fatalf("%d resets, %d transfers", sim.resets, len(sim.xfers))
+	}
+
+	for i, x := range want {
+		if got := sim.xfers[i]; got.dc != x.dc || !bytes.Equal(got.p, x.p) {
+			t.Fatalf("transfer %d: got dc %v % x, want dc %v % x", i, got.dc, got.p, x.dc, x.p)
+		}
+	}
+
+	sim.xfers = nil
+
+	d.SetPixel(3, 18, true) // Page 2, bit 2.
+	if err := d.FlushPage(2); err != nil {
+		t.Fatal(err)
+	}
+
+	page := make([]byte, 128)
+	page[3] = 0x04
+
+	if len(sim.xfers) != 2 ||
+		!bytes.Equal(sim.xfers[0].p, []byte{0x21, 0x00, 0x7f, 0x22, 0x02, 0x02}) || sim.xfers[0].dc ||
+		!bytes.Equal(sim.xfers[1].p, page) || !sim.xfers[1].dc {
+		t.Fatalf("page flush: got %+v", sim.xfers)
+	}
+
+	if err := d.FlushPage(8); err == nil {
+		t.Fatal("page 8 accepted")
+	}
+}
+
+func TestDisplayOffset(t *testing.T) {
+	sim := &oledSim{t: t}
+
+	if _, err := New(&ch347.IO{Dev: sim}, Config{DC: ch347.GPIO1, RST: ch347.GPIO5, Width: 64, Height: 48, SkipReset: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 64 columns centered in 128 columns RAM.
+	if x := sim.xfers[1]; !bytes.Equal(x.p, []byte{0x21, 32, 95, 0x22, 0x00, 0x05}) {
+		t.Fatalf("got % x", x.p)
+	}
+
+	if sim.resets != 0 {
+		t.Fatal("reset with SkipReset")
+	}
+}
+
+func TestScrollX(t *testing.T) {
+	// 128 pixels wide display, 12 pixels wide text.
+	for _, tc := range []struct{ step, want int }{
+		{0, 128},   // Right behind the right edge.
+		{1, 127},   // A pixel a step.
+		{128, 0},   // At the left edge.
+		{139, -11}, // Last column still visible.
+		{140, 128}, // Fully left, starts over.
+		{141, 127},
+	} {
+		if x := scrollX(tc.step, 128, 12); x != tc.want {
+			t.Errorf("step %d: got %d, want %d", tc.step, x, tc.want)
+		}
+	}
+}
+
+func TestScrollText(t *testing.T) {
+	sim := &oledSim{t: t}
+
+	d, err := New(&ch347.IO{Dev: sim}, Config{DC: ch347.GPIO1, RST: ch347.GPIO5, SkipReset: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim.xfers = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := d.ScrollText(ctx, 3, "Hi", time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatalf("got %v", err)
+	}
+
+	// Page 3 only, every step shifted left by a pixel.
+	var frames [][]byte
+	for _, x := range sim.xfers {
+		if !x.dc {
+			if !bytes.Equal(x.p, []byte{0x21, 0x00, 0x7f, 0x22, 0x03, 0x03}) {
+				t.Fatalf("got command % x", x.p)
+			}
+
+			continue
+		}
+
+		frames = append(frames, x.p)
+	}
+
+	if len(frames) < 5 || len(frames) > 128 {
+		t.Fatalf("%d steps", len(frames))
+	}
+
+	for i := 1; i < len(frames); i++ {
+		prev, cur := frames[i-1], frames[i]
+		if !bytes.Equal(cur[:127], prev[1:]) {
+			t.Fatalf("step %d: got % x, previous % x", i, cur, prev)
+		}
+	}
+
+	if last := frames[len(frames)-1]; bytes.Equal(last, make([]byte, 128)) {
+		t.Fatal("text never entered the display")
+	}
+}
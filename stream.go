@@ -0,0 +1,177 @@
+package ch347
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+// Delay before buffered UARTStream writes are sent.
+const streamFlushDelay = 5 * time.Millisecond
+
+// UARTStream is a buffered bidirectional byte stream over UART.
+//
+// Received data is read in the background into a buffer, written data is
+// buffered and sent shortly after the write or on Flush.
+type UARTStream struct {
+	u *UART
+
+	rmu    sync.Mutex
+	rcond  *sync.Cond
+	rbuf   []byte
+	rmax   int
+	rerr   error
+	closed bool
+	done   chan struct{}
+
+	wmu     sync.Mutex
+	w       *bufio.Writer
+	timer   *time.Timer
+	wclosed bool
+}
+
+// NewUARTStream starts reading UART in the background with up to readBuf bytes buffered,
+// and buffers up to writeBuf bytes of writes.
+//
+// Reports are always read whole, so readBuf is at least 510 bytes, a single report.
+//
+// Dev must implement ReadWithTimeout, otherwise Close blocks until the next byte is received.
+func NewUARTStream(c *UART, readBuf, writeBuf int) *UARTStream {
+	s := &UARTStream{
+		u:    c,
+		rmax: max(readBuf, 510),
+		done: make(chan struct{}),
+		w:    bufio.NewWriterSize(c, writeBuf),
+	}
+	s.rcond = sync.NewCond(&s.rmu)
+
+	go s.readLoop()
+
+	return s
+}
+
+// Read reads buffered data, waiting for it if the buffer is empty.
+func (s *UARTStream) Read(p []byte) (int, error) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+
+	for len(s.rbuf) == 0 && s.rerr == nil && !s.closed {
+		s.rcond.Wait()
+	}
+
+	if len(s.rbuf) == 0 {
+		if s.closed {
+			return 0, io.ErrClosedPipe
+		}
+
+		return 0, s.rerr
+	}
+
+	n := copy(p, s.rbuf)
+	s.rbuf = s.rbuf[:copy(s.rbuf, s.rbuf[n:])]
+	s.rcond.Broadcast()
+
+	return n, nil
+}
+
+// Write buffers p, it's sent once the buffer fills up, shortly after or on Flush.
+// Returns io.ErrClosedPipe after Close.
+func (s *UARTStream) Write(p []byte) (int, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if s.wclosed {
+		return 0, io.ErrClosedPipe
+	}
+
+	n, err := s.w.Write(p)
+
+	if s.w.Buffered() > 0 && s.timer == nil {
+		s.timer = time.AfterFunc(streamFlushDelay, func() { s.Flush() })
+	}
+
+	return n, err
+}
+
+// Flush sends buffered writes.
+func (s *UARTStream) Flush() error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if s.wclosed {
+		return io.ErrClosedPipe
+	}
+
+	return s.flush()
+}
+
+func (s *UARTStream) flush() error {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	return s.w.Flush()
+}
+
+// Close flushes buffered writes and stops background reading.
+func (s *UARTStream) Close() error {
+	s.wmu.Lock()
+	if s.wclosed {
+		s.wmu.Unlock()
+		return io.ErrClosedPipe
+	}
+
+	err := s.flush()
+	s.wclosed = true
+	s.wmu.Unlock()
+
+	s.rmu.Lock()
+	s.closed = true
+	s.rcond.Broadcast()
+	s.rmu.Unlock()
+
+	<-s.done
+
+	return err
+}
+
+func (s *UARTStream) readLoop() {
+	defer close(s.done)
+
+	p := make([]byte, 510)
+
+	for {
+		// Wait for room for a whole report, reading less would drop the rest of it.
+		s.rmu.Lock()
+		for s.rmax-len(s.rbuf) < len(p) && !s.closed {
+			s.rcond.Wait()
+		}
+
+		closed := s.closed
+		s.rmu.Unlock()
+
+		if closed {
+			return
+		}
+
+		s.u.readMu.Lock()
+		n, err := s.u.read(p, 100*time.Millisecond)
+		s.u.readMu.Unlock()
+
+		if err == ErrTimeout {
+			continue
+		}
+
+		s.rmu.Lock()
+		s.rbuf = append(s.rbuf, p[:n]...)
+		s.rerr = err
+		s.rcond.Broadcast()
+		s.rmu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package ch347
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestUARTStreamWholeReports(t *testing.T) {
+	d := &mockDev{}
+
+	var want []byte
+	for i := 0; i < 4; i++ {
+		p := make([]byte, 512)
+		p[0], p[1] = 0xfe, 0x01 // 510 bytes.
+		for j := range p[2:] {
+			p[2+j] = byte(i*510 + j)
+		}
+
+		want = append(want, p[2:]...)
+		d.queue(p)
+	}
+
+	s := NewUARTStream(&UART{Dev: d}, 100, 64)
+	defer s.Close()
+
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(s, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatal("received data mismatch")
+	}
+}
+
+func TestUARTStreamWriteAfterClose(t *testing.T) {
+	s := NewUARTStream(&UART{Dev: &mockDev{}}, 512, 64)
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Write([]byte("hi")); err != io.ErrClosedPipe {
+		t.Fatalf("got %v, want io.ErrClosedPipe", err)
+	}
+}
+
+func TestUARTStreamWriteFlush(t *testing.T) {
+	d := &mockDev{}
+	s := NewUARTStream(&UART{Dev: d}, 512, 64)
+	defer s.Close()
+
+	// Small writes are buffered, then sent together after the flush delay.
+	s.Write([]byte("ab"))
+	s.Write([]byte("c"))
+
+	if n := len(d.written()); n != 0 {
+		t.Fatalf("%d packets written right away", n)
+	}
+
+	time.Sleep(10 * streamFlushDelay)
+
+	if writes := d.written(); len(writes) != 1 || string(writes[0]) != "\x03\x00abc" {
+		t.Fatalf("got %q", writes)
+	}
+
+	// Flush sends right away.
+	s.Write([]byte("d"))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if writes := d.written(); len(writes) != 2 || string(writes[1]) != "\x01\x00d" {
+		t.Fatalf("got %q", writes)
+	}
+}
+
+func TestUARTStreamClose(t *testing.T) {
+	d := &mockDev{}
+	s := NewUARTStream(&UART{Dev: d}, 512, 64)
+
+	read := make(chan error)
+	go func() {
+		_, err := s.Read(make([]byte, 1))
+		read <- err
+	}()
+
+	s.Write([]byte("bye"))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pending writes are flushed, waiting Read is released.
+	if writes := d.written(); len(writes) != 1 || string(writes[0]) != "\x03\x00bye" {
+		t.Fatalf("got %q", writes)
+	}
+
+	select {
+	case err := <-read:
+		if err != io.ErrClosedPipe {
+			t.Fatalf("Read: got %v, want io.ErrClosedPipe", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read blocked after Close")
+	}
+
+	if err := s.Close(); err != io.ErrClosedPipe {
+		t.Fatalf("second Close: got %v", err)
+	}
+}
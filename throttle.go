@@ -0,0 +1,28 @@
+package ch347
+
+import (
+	"sync"
+	"time"
+)
+
+// throttle spaces operations by a minimum interval.
+type throttle struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// wait sleeps until at least interval has passed since the previous call.
+func (t *throttle) wait(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if d := interval - time.Since(t.last); d > 0 {
+		time.Sleep(d)
+	}
+
+	t.last = time.Now()
+}
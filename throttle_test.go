@@ -0,0 +1,65 @@
+package ch347
+
+import (
+	"testing"
+	"time"
+)
+
+// stampDev is mockDev recording time of every write.
+type stampDev struct {
+	mockDev
+	stamps []time.Time
+}
+
+func (d *stampDev) Write(p []byte) (int, error) {
+	d.stamps = append(d.stamps, time.Now())
+	return d.mockDev.Write(p)
+}
+
+func TestMinOpInterval(t *testing.T) {
+	const interval = 20 * time.Millisecond
+
+	var pins [8]byte
+	d := &stampDev{mockDev: mockDev{respond: gpioResponder(&pins)}}
+	c := &IO{Dev: d, MinOpInterval: interval}
+
+	for _, level := range []bool{true, false, true} {
+		if err := c.WritePin(GPIO2, true, level); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := &stampDev{}
+	uc := &UART{Dev: u, MinOpInterval: interval}
+
+	for i := 0; i < 3; i++ {
+		if _, err := uc.Write([]byte("hi")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, stamps := range [][]time.Time{d.stamps, u.stamps} {
+		if len(stamps) != 3 {
+			t.Fatalf("%d writes", len(stamps))
+		}
+
+		for i := 1; i < len(stamps); i++ {
+			if gap := stamps[i].Sub(stamps[i-1]); gap < interval {
+				t.Fatalf("write %d: %v after the previous one", i, gap)
+			}
+		}
+	}
+
+	// No throttling by default.
+	d.stamps = nil
+	c.MinOpInterval = 0
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		c.WritePin(GPIO2, true, i%2 == 0)
+	}
+
+	if elapsed := time.Since(start); elapsed >= interval {
+		t.Fatalf("unthrottled writes took %v", elapsed)
+	}
+}
@@ -1,5 +1,18 @@
 package ch347
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	ErrCRC     = errors.New("crc check failed")
+	ErrTimeout = errors.New("timeout")
+)
+
 type UARTDataBits uint8
 type UARTParity uint8
 type UARTStopBit uint8
@@ -33,20 +46,115 @@ func (c *UART) Set(baudRate uint32, dataBits UARTDataBits, parity UARTParity, st
 		0xcb, 0x08, 0x00, // cmd
 		byte((baudRate >> 0) & 0xff), byte((baudRate >> 8) & 0xff), byte((baudRate >> 16) & 0xff),
 		0x00,
-		byte(stop), byte(parity), byte(dataBits), 0x00, /*timeout*/
+		byte(stop), byte(parity), byte(dataBits), byteTimeout(c.ByteTimeout),
 	}
 
-	_, err := c.Dev.SendFeatureReport(p)
+	c.cfgMu.Lock()
+	n, err := c.Dev.SendFeatureReport(p)
+
+	if err == nil && n < len(p) {
+		// Partially applied config is still a failure.
+		err = fmt.Errorf("%w: %d of %d bytes of uart config sent", io.ErrShortWrite, n, len(p))
+	}
+
+	if err == nil {
+		c.lineCoding = p
+	}
+	c.cfgMu.Unlock()
 
 	if err != nil {
 		return err
 	}
 
+	// Data received with old settings is garbage.
+	if c.DiscardOnSet {
+		return c.DiscardInput()
+	}
+
 	return nil
 }
 
+// byteTimeout returns byte timeout config value in 100us units.
+func byteTimeout(d time.Duration) byte {
+	units := (d + 50*time.Microsecond) / (100 * time.Microsecond)
+	if units > 0xff {
+		units = 0xff
+	}
+
+	if units < 0 {
+		units = 0
+	}
+
+	return byte(units)
+}
+
+// restore re-applies last configuration sent by Set.
+func (c *UART) restore() error {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+
+	if c.lineCoding == nil {
+		return nil
+	}
+
+	n, err := c.Dev.SendFeatureReport(c.lineCoding)
+	if err == nil && n < len(c.lineCoding) {
+		err = io.ErrShortWrite
+	}
+
+	return err
+}
+
+// DiscardInput reads and discards all data pending in the receive buffer.
+// It gives up after 100ms if data keeps coming.
+//
+// Dev must implement ReadWithTimeout, otherwise errors.ErrUnsupported is returned.
+func (c *UART) DiscardInput() error {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	d, ok := c.Dev.(timeoutReader)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	return drain(d)
+}
+
 // Read implementes reader interface.
+//
+// Reads and writes may run concurrently. Concurrent reads are serialized, and so are writes.
 func (c *UART) Read(b []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	c.activity()
+
+	return c.read(b, 0)
+}
+
+// ReadRaw reads a single unprocessed report into p.
+//
+// Report layout:
+//   - bytes 0-1 - payload length, little-endian.
+//   - bytes 2.. - payload, up to 510 bytes.
+//
+// Pass at least 512 bytes long p to receive a full report.
+func (c *UART) ReadRaw(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	c.activity()
+	c.throttle.wait(c.MinOpInterval)
+
+	n, err := c.Dev.Read(p)
+	c.metrics.done(&c.metrics.rxBytes, n, err)
+
+	return n, err
+}
+
+// read reads a single report. Non-zero timeout is used if Dev implements ReadWithTimeout.
+func (c *UART) read(b []byte, timeout time.Duration) (int, error) {
 	plen := len(b)
 
 	// Maximum 510 bytes per reads.
@@ -57,30 +165,148 @@ func (c *UART) Read(b []byte) (int, error) {
 	// 2 bytes length in the begining.
 	p := make([]byte, plen+2)
 
-	_, err := c.Dev.Read(p)
-	if err != nil {
-		return 0, err
+	c.throttle.wait(c.MinOpInterval)
+
+	var rn int
+	var err error
+	if d, ok := c.Dev.(timeoutReader); ok && timeout > 0 {
+		rn, err = readWithTimeout(d, p, timeout)
+	} else {
+		rn, err = c.Dev.Read(p)
 	}
 
-	n := (int(p[1]) << 8) | int(p[0])
+	// Dev with read timeouts, like the one from HIDDev note, reports idle line with its own error.
+	if isTimeout(err) {
+		err = ErrTimeout
+	}
 
-	if n > len(b) {
-		n = len(b)
+	if err != nil {
+		c.metrics.done(&c.metrics.rxBytes, 0, err)
+		return 0, err
 	}
 
+	n := uartPayloadLen(p[:rn])
+
 	copy(b[:n], p[2:])
+	c.metrics.done(&c.metrics.rxBytes, n, nil)
 
 	return n, nil
 }
 
+// uartPayloadLen returns payload length of report p, which is p length if it's truncated.
+//
+// Length header is trusted: zero length means no data even if garbage follows it,
+// as the chip doesn't clear report tail. Header bigger than the payload received is
+// clamped to it instead of returning stale buffer bytes.
+func uartPayloadLen(p []byte) int {
+	if len(p) < 2 {
+		return 0
+	}
+
+	n := (int(p[1]) << 8) | int(p[0])
+
+	return min(n, len(p)-2)
+}
+
 // Write implementes writer interface.
+//
+// Data is sent in chunks of up to 510 bytes. If writing a chunk fails, Write returns
+// the number of bytes sent in previous chunks along with a non-nil error.
+// A short device write is reported as io.ErrShortWrite.
+//
+// With CoalesceDelay set, data is buffered instead, see CoalesceDelay.
 func (c *UART) Write(b []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.CoalesceDelay <= 0 {
+		return c.write(b)
+	}
+
+	if err := c.coalesceErr; err != nil {
+		c.coalesceErr = nil
+		return 0, err
+	}
+
+	c.wbuf = append(c.wbuf, b...)
+
+	// Full report is ready.
+	if len(c.wbuf) >= 510 {
+		return len(b), c.flush()
+	}
+
+	if c.flushTimer == nil {
+		var t *time.Timer
+		t = time.AfterFunc(c.CoalesceDelay, func() {
+			c.writeMu.Lock()
+			defer c.writeMu.Unlock()
+
+			// Flushed while waiting for the lock, pending timer, if any, is a newer one.
+			if c.flushTimer != t {
+				return
+			}
+
+			c.flushTimer = nil
+			if err := c.flush(); err != nil {
+				c.coalesceErr = err
+			}
+		})
+		c.flushTimer = t
+	}
+
+	return len(b), nil
+}
+
+// Flush sends data buffered by Write with CoalesceDelay set right away.
+// It also returns an error of a previous delayed send.
+func (c *UART) Flush() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	err := c.flush()
+	if err == nil {
+		err, c.coalesceErr = c.coalesceErr, nil
+	}
+
+	return err
+}
+
+// flush sends buffered data. Unsent data stays buffered.
+func (c *UART) flush() error {
+	if c.flushTimer != nil {
+		c.flushTimer.Stop()
+		c.flushTimer = nil
+	}
+
+	if len(c.wbuf) == 0 {
+		return nil
+	}
+
+	n, err := c.write(c.wbuf)
+	c.wbuf = c.wbuf[:copy(c.wbuf, c.wbuf[n:])]
+
+	return err
+}
+
+func (c *UART) write(b []byte) (int, error) {
+	c.activity()
+
 	plen := len(b)
 
 	// Maximum 510 bytes per writes.
 	if plen > 510 {
 		plen = 510
 	}
+
+	c.cfgMu.Lock()
+	delay := c.interCharDelay
+	c.cfgMu.Unlock()
+
+	// Send byte by byte with delay in between.
+	if delay > 0 && plen > 1 {
+		plen = 1
+	}
+
 	p := make([]byte, plen+2)
 
 	var pos, dlen, wlen int
@@ -100,13 +326,154 @@ func (c *UART) Write(b []byte) (int, error) {
 			p = p[:2+dlen]
 		}
 
-		_, err := c.Dev.Write(p)
+		c.throttle.wait(c.MinOpInterval)
+
+		n, err := c.Dev.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+
+		c.metrics.done(&c.metrics.txBytes, dlen, err)
 		if err != nil {
 			return pos, err
 		}
 
 		pos += dlen
+
+		if delay > 0 && pos < wlen {
+			time.Sleep(delay)
+		}
 	}
 
 	return pos, nil
 }
+
+// SetInterCharDelay makes Write send data byte by byte with given delay in between.
+// Zero delay disables it.
+//
+// Delay is software timed, actual gap between bytes is at least d plus USB latency.
+func (c *UART) SetInterCharDelay(d time.Duration) {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+
+	c.interCharDelay = d
+}
+
+// ReadCRCFrame reads a frame of given length and verifies its trailing CRC.
+//
+// Last crcLen bytes of the frame are the CRC, e.g. 2 bytes for CRC-16.
+// crcFn must return CRC of the given data as it's sent over the wire.
+// ErrCRC is returned on CRC mismatch along with the frame.
+// ErrTimeout is returned if frame wasn't received in time.
+// Dev must implement ReadWithTimeout for the timeout to interrupt pending reads.
+//
+// Example:
+//
+//	// Read Modbus response.
+//	f, err := c.ReadCRCFrame(23, 2, func(p []byte) []byte {
+//		crc := ch347.CRC16Modbus(p)
+//		return []byte{byte(crc), byte(crc >> 8)}
+//	}, 1*time.Second)
+func (c *UART) ReadCRCFrame(length, crcLen int, crcFn func([]byte) []byte, timeout time.Duration) ([]byte, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if crcLen <= 0 || length < crcLen {
+		return nil, fmt.Errorf("frame length %d doesn't fit %d bytes crc", length, crcLen)
+	}
+
+	c.activity()
+
+	p := make([]byte, length)
+	deadline := time.Now().Add(timeout)
+
+	for pos := 0; pos < length; {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return nil, ErrTimeout
+		}
+
+		n, err := c.read(p[pos:], left)
+		if err != nil {
+			if time.Now().After(deadline) {
+				return nil, ErrTimeout
+			}
+
+			return nil, err
+		}
+
+		pos += n
+	}
+
+	if !bytes.Equal(crcFn(p[:length-crcLen]), p[length-crcLen:]) {
+		return p, ErrCRC
+	}
+
+	return p, nil
+}
+
+// Expect reads until one of the patterns appears or the timeout fires.
+// It returns the matched pattern and everything read so far.
+//
+// ErrTimeout is returned along with data read if none of the patterns appeared in time.
+// Dev must implement ReadWithTimeout for the timeout to interrupt pending reads.
+//
+// Example:
+//
+//	fmt.Fprint(c, "AT\r\n")
+//	m, _, err := c.Expect([]string{"OK\r\n", "ERROR\r\n"}, 1*time.Second)
+func (c *UART) Expect(patterns []string, timeout time.Duration) (matched string, buf []byte, err error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	c.activity()
+
+	p := make([]byte, 510)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return "", buf, ErrTimeout
+		}
+
+		n, err := c.read(p, left)
+		if err != nil {
+			if time.Now().After(deadline) {
+				return "", buf, ErrTimeout
+			}
+
+			return "", buf, err
+		}
+
+		buf = append(buf, p[:n]...)
+
+		for _, pattern := range patterns {
+			if bytes.Contains(buf, []byte(pattern)) {
+				return pattern, buf, nil
+			}
+		}
+	}
+}
+
+// Close sends buffered data and closes Dev, if it implements io.Closer,
+// once reads and writes in progress are done.
+// A read waiting for data holds Close up, see HIDDev note about read timeouts.
+func (c *UART) Close() error {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return errors.Join(c.flush(), closeDev(c.Dev))
+}
+
+func (c *UART) activity() {
+	if c.ActivityIO == nil {
+		return
+	}
+
+	c.ActivityIO.mu.Lock()
+	c.ActivityIO.activity()
+	c.ActivityIO.mu.Unlock()
+}
@@ -0,0 +1,438 @@
+package ch347
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUARTCoalesce(t *testing.T) {
+	d := &mockDev{}
+	c := &UART{Dev: d, CoalesceDelay: 20 * time.Millisecond}
+
+	for _, b := range []string{"a", "b", "c"} {
+		if _, err := c.Write([]byte(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	writes := d.written()
+	if len(writes) != 1 || !bytes.Equal(writes[0], []byte{3, 0, 'a', 'b', 'c'}) {
+		t.Fatalf("got % x, want a single write", writes)
+	}
+}
+
+func TestUARTCoalesceFlushRace(t *testing.T) {
+	d := &mockDev{}
+	c := &UART{Dev: d, CoalesceDelay: time.Millisecond}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 200; j++ {
+				c.Write([]byte{byte(j)})
+				if j%7 == 0 {
+					c.Flush()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for _, p := range d.written() {
+		n += len(p) - 2
+	}
+
+	if n != 4*200 {
+		t.Fatalf("%d bytes written, want %d", n, 4*200)
+	}
+}
+
+func TestUARTSetRace(t *testing.T) {
+	c := &UART{Dev: &mockDev{}}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 100; i++ {
+			c.Set(9600, UARTDataBits8, UARTParityNone, UARTStopBitOne)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 100; i++ {
+			c.SelfTest()
+		}
+	}()
+
+	wg.Wait()
+}
+
+func TestUARTPayloadLen(t *testing.T) {
+	for _, tc := range []struct {
+		p    []byte
+		want int
+	}{
+		{p: nil, want: 0},
+		{p: []byte{5}, want: 0},
+		{p: []byte{0, 0, 'x', 'x'}, want: 0},       // Stale tail ignored.
+		{p: []byte{2, 0, 'h', 'i', 'x'}, want: 2},  // Header trusted.
+		{p: []byte{0xfe, 0x01, 'h', 'i'}, want: 2}, // Truncated report clamped.
+	} {
+		if got := uartPayloadLen(tc.p); got != tc.want {
+			t.Fatalf("% x: got %d, want %d", tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestUARTReadClamp(t *testing.T) {
+	d := &mockDev{}
+	d.queue([]byte{0xfe, 0x01, 'h', 'i'}) // Header claims 510 bytes, 2 delivered.
+	c := &UART{Dev: d}
+
+	b := make([]byte, 510)
+	n, err := c.Read(b)
+	if err != nil || string(b[:n]) != "hi" {
+		t.Fatalf("got %q, %v", b[:n], err)
+	}
+}
+
+// streamingDev is HIDDev receiving data nonstop.
+type streamingDev struct {
+	mockDev
+}
+
+func (d *streamingDev) Read(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	return copy(p, []byte{0x01, 0x00, 'x'}), nil
+}
+
+func (d *streamingDev) ReadWithTimeout(p []byte, _ time.Duration) (int, error) {
+	return d.Read(p)
+}
+
+func TestUARTDiscardInput(t *testing.T) {
+	d := &mockDev{}
+	d.queue([]byte{0x02, 0x00, 'a', 'b'}, []byte{0x01, 0x00, 'c'})
+
+	c := &UART{Dev: d, DiscardOnSet: true}
+	if err := c.Set(115200, UARTDataBits8, UARTParityNone, UARTStopBitOne); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.resps) != 0 {
+		t.Fatalf("%d reports left after Set", len(d.resps))
+	}
+
+	// Gives up on data coming nonstop.
+	s := &UART{Dev: &streamingDev{}}
+
+	start := time.Now()
+	if err := s.DiscardInput(); err != nil {
+		t.Fatal(err)
+	}
+
+	if took := time.Since(start); took < drainTimeout || took > 5*drainTimeout {
+		t.Fatalf("took %v", took)
+	}
+}
+
+func TestUARTInterCharDelay(t *testing.T) {
+	d := &mockDev{}
+	c := &UART{Dev: d}
+	c.SetInterCharDelay(5 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := c.Write([]byte("abcd")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Byte by byte, 3 gaps in between.
+	if took := time.Since(start); took < 15*time.Millisecond {
+		t.Fatalf("took %v", took)
+	}
+
+	want := [][]byte{{1, 0, 'a'}, {1, 0, 'b'}, {1, 0, 'c'}, {1, 0, 'd'}}
+	if got := d.written(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got % x", got)
+	}
+
+	// Changed while writing.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Write([]byte("abcd"))
+	}()
+
+	c.SetInterCharDelay(0)
+	<-done
+}
+
+func TestReadCRCFrame(t *testing.T) {
+	frame := BuildModbusRequest(0x01, 0x04, []byte{0x02, 0x00, 0xe6})
+	crc16 := func(p []byte) []byte {
+		crc := CRC16Modbus(p)
+		return []byte{byte(crc), byte(crc >> 8)}
+	}
+
+	// Frame split across reports.
+	d := &mockDev{}
+	d.queue(append([]byte{3, 0}, frame[:3]...), append([]byte{4, 0}, frame[3:]...))
+
+	c := &UART{Dev: d}
+	p, err := c.ReadCRCFrame(len(frame), 2, crc16, time.Second)
+	if err != nil || !bytes.Equal(p, frame) {
+		t.Fatalf("got % x, %v", p, err)
+	}
+
+	// CRC mismatch, frame is still returned.
+	bad := append([]byte(nil), frame...)
+	bad[4] ^= 0xff
+	d.queue(append([]byte{byte(len(bad)), 0}, bad...))
+
+	if p, err := c.ReadCRCFrame(len(bad), 2, crc16, time.Second); err != ErrCRC || !bytes.Equal(p, bad) {
+		t.Fatalf("got % x, %v, want ErrCRC", p, err)
+	}
+
+	// Frame cut short.
+	d.queue(append([]byte{3, 0}, frame[:3]...))
+
+	if _, err := c.ReadCRCFrame(len(frame), 2, crc16, 20*time.Millisecond); err != ErrTimeout {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+
+	if _, err := c.ReadCRCFrame(1, 2, crc16, time.Second); err == nil {
+		t.Fatal("frame shorter than crc accepted")
+	}
+}
+
+func TestUARTReadRaw(t *testing.T) {
+	// Stale bytes past the payload are part of the report too.
+	report := []byte{0x05, 0x00, 'h', 'e', 'l', 'l', 'o', 0xaa, 0xbb}
+
+	d := &mockDev{}
+	d.queue(report)
+	c := &UART{Dev: d}
+
+	p := make([]byte, 512)
+	n, err := c.ReadRaw(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(p[:n], report) {
+		t.Fatalf("got % x, want % x", p[:n], report)
+	}
+
+	if m := c.Metrics(); m.RxBytes != uint64(len(report)) {
+		t.Fatalf("%d bytes counted", m.RxBytes)
+	}
+}
+
+func TestUARTExpect(t *testing.T) {
+	// Response split across reports, pattern spans two of them.
+	d := &mockDev{}
+	d.queue([]byte{0x04, 0x00, 'A', 'T', '\r', '\n'}, []byte{0x01, 0x00, 'O'}, []byte{0x03, 0x00, 'K', '\r', '\n'})
+	c := &UART{Dev: d}
+
+	m, buf, err := c.Expect([]string{"ERROR\r\n", "OK\r\n"}, time.Second)
+	if err != nil || m != "OK\r\n" || string(buf) != "AT\r\nOK\r\n" {
+		t.Fatalf("got %q, %q, %v", m, buf, err)
+	}
+
+	// None of the patterns shows up.
+	d.queue([]byte{0x03, 0x00, 'E', 'R', 'R'})
+
+	m, buf, err = c.Expect([]string{"ERROR\r\n", "OK\r\n"}, 20*time.Millisecond)
+	if err != ErrTimeout || m != "" || string(buf) != "ERR" {
+		t.Fatalf("got %q, %q, %v", m, buf, err)
+	}
+}
+
+// shortFeatureDev accepts all but the last byte of feature reports.
+type shortFeatureDev struct {
+	mockDev
+}
+
+func (d *shortFeatureDev) SendFeatureReport(p []byte) (int, error) {
+	d.mockDev.SendFeatureReport(p)
+	return len(p) - 1, nil
+}
+
+func TestUARTSetShortFeatureReport(t *testing.T) {
+	d := &shortFeatureDev{}
+	c := &UART{Dev: d}
+
+	if err := c.Set(115200, UARTDataBits8, UARTParityNone, UARTStopBitOne); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("got %v, want io.ErrShortWrite", err)
+	}
+
+	// Failed config is not re-applied.
+	for _, ch := range c.SelfTest() {
+		if ch.Name == "uart config" {
+			t.Fatal("failed config kept")
+		}
+	}
+}
+
+// gateDev is mockDev whose Read and Write wait for their gates to be closed, if set.
+type gateDev struct {
+	mockDev
+	readGate, writeGate chan struct{}
+}
+
+func (d *gateDev) Read(p []byte) (int, error) {
+	if d.readGate != nil {
+		<-d.readGate
+	}
+
+	return copy(p, []byte{0x01, 0x00, 'x'}), nil
+}
+
+func (d *gateDev) Write(p []byte) (int, error) {
+	if d.writeGate != nil {
+		<-d.writeGate
+	}
+
+	return d.mockDev.Write(p)
+}
+
+func TestUARTReadWriteConcurrent(t *testing.T) {
+	// Write completes while Read waits for data.
+	d := &gateDev{readGate: make(chan struct{})}
+	c := &UART{Dev: d}
+
+	read := make(chan error)
+	go func() {
+		_, err := c.Read(make([]byte, 16))
+		read <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	wrote := make(chan error)
+	go func() {
+		_, err := c.Write([]byte("hi"))
+		wrote <- err
+	}()
+
+	select {
+	case err := <-wrote:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Write blocked by Read")
+	}
+
+	close(d.readGate)
+	if err := <-read; err != nil {
+		t.Fatal(err)
+	}
+
+	// Read completes while Write is stuck.
+	d = &gateDev{writeGate: make(chan struct{})}
+	c = &UART{Dev: d}
+
+	go func() {
+		_, err := c.Write([]byte("hi"))
+		wrote <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	go func() {
+		_, err := c.Read(make([]byte, 16))
+		read <- err
+	}()
+
+	select {
+	case err := <-read:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read blocked by Write")
+	}
+
+	close(d.writeGate)
+	if err := <-wrote; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUARTWritesDontInterleave(t *testing.T) {
+	d := &mockDev{}
+	c := &UART{Dev: d}
+
+	// Each write takes several reports.
+	var wg sync.WaitGroup
+	for _, b := range []byte("ab") {
+		wg.Add(1)
+		go func(b byte) {
+			defer wg.Done()
+
+			if _, err := c.Write(bytes.Repeat([]byte{b}, 2000)); err != nil {
+				t.Error(err)
+			}
+		}(b)
+	}
+	wg.Wait()
+
+	var got []byte
+	for _, p := range d.written() {
+		got = append(got, p[2:]...)
+	}
+
+	a, b := bytes.Repeat([]byte("a"), 2000), bytes.Repeat([]byte("b"), 2000)
+	if !bytes.Equal(got, append(a, b...)) && !bytes.Equal(got, append(b, a...)) {
+		t.Fatal("writes interleaved")
+	}
+}
+
+func TestUARTByteTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		timeout time.Duration
+		want    byte
+	}{
+		{0, 0x00}, // Chip default.
+		{100 * time.Microsecond, 0x01},
+		{150 * time.Microsecond, 0x02}, // Rounded to 100us units.
+		{time.Millisecond, 0x0a},
+		{25500 * time.Microsecond, 0xff},
+		{time.Second, 0xff}, // Clamped.
+		{-time.Millisecond, 0x00},
+	} {
+		d := &mockDev{}
+		c := &UART{Dev: d, ByteTimeout: tc.timeout}
+
+		if err := c.Set(115200, UARTDataBits8, UARTParityNone, UARTStopBitOne); err != nil {
+			t.Fatal(err)
+		}
+
+		// 115200 baud, 1 stop bit, no parity, 8 data bits, byte timeout.
+		want := []byte{0xcb, 0x08, 0x00, 0x00, 0xc2, 0x01, 0x00, 0x00, 0x00, 0x03, tc.want}
+		if len(d.features) != 1 || !bytes.Equal(d.features[0], want) {
+			t.Fatalf("%v: got % x, want % x", tc.timeout, d.features, want)
+		}
+	}
+}
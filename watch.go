@@ -0,0 +1,285 @@
+package ch347
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInvalidEdge is returned by WatchPin for unknown Edge values.
+var ErrInvalidEdge = errors.New("invalid edge")
+
+// Default interval of the pin poller.
+const defaultPollInterval = 10 * time.Millisecond
+
+// PinEvent is a pin level change reported by WatchPinContext.
+type PinEvent struct {
+	Pin   Pin
+	Level bool      // New pin level, as returned by ReadPin.
+	Time  time.Time // When the change was noticed.
+
+	// Since is time passed since the previous event of this pin,
+	// or since watching started for the first event.
+	Since time.Duration
+}
+
+// pinPoller reads all pins with a single request per interval for all watchers.
+type pinPoller struct {
+	mu       sync.Mutex
+	interval time.Duration
+	watchers []*pinWatcher
+	running  bool
+	stopped  bool          // Set by stopWatchers, no more watching.
+	stop     chan struct{} // Closed by stopWatchers.
+	done     chan struct{} // Closed once poller goroutine exits.
+}
+
+type pinWatcher struct {
+	ctx  context.Context
+	pin  Pin
+	ch   chan PinEvent
+	last PinEvent
+	seen bool // Initial level is known.
+}
+
+// SetPollInterval sets how often pins are polled for WatchPin and WatchPinContext. Defaults to 10ms.
+// It takes effect from the next poll.
+func (c *IO) SetPollInterval(d time.Duration) {
+	c.poller.mu.Lock()
+	defer c.poller.mu.Unlock()
+
+	c.poller.interval = d
+}
+
+// WatchPinContext sends an event on every pin level change, until ctx is cancelled.
+// Returned channel is closed then. ErrInvalidPin is returned for pins other than GPIO0-GPIO7,
+// and an error if the pin is assigned to its alternate function with AssignPin.
+//
+// All watchers share a single background poller, reading every pin with one request
+// per interval set by SetPollInterval. Poller runs only while there are watchers.
+//
+// Timestamps are taken when a poll has returned, so an edge is noticed up to poll interval
+// plus USB latency (about 1ms) late, and pulses shorter than the interval can be missed.
+// Read errors are skipped, polling goes on. A watcher not receiving its events holds up others.
+//
+// Example:
+//
+//	// Measure button press duration.
+//	c.SetPollInterval(5 * time.Millisecond)
+//
+//	events, err := c.WatchPinContext(ctx, ch347.GPIO3)
+//	if err != nil {
+//		return err
+//	}
+//
+//	for ev := range events {
+//		if !ev.Level {
+//			fmt.Println("pressed for", ev.Since)
+//		}
+//	}
+func (c *IO) WatchPinContext(ctx context.Context, pin Pin) (<-chan PinEvent, error) {
+	// Checked here, poller goroutine has no way to report it.
+	c.mu.Lock()
+	err := c.checkPinRole(pin, PinRoleGPIO)
+	c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := &pinWatcher{ctx: ctx, pin: pin, ch: make(chan PinEvent, 16)}
+
+	p := &c.poller
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		close(w.ch)
+		return w.ch, nil
+	}
+
+	p.watchers = append(p.watchers, w)
+
+	if p.stop == nil {
+		p.stop = make(chan struct{})
+	}
+
+	if !p.running {
+		p.running = true
+		p.done = make(chan struct{})
+
+		go func(done chan struct{}) {
+			defer close(done)
+			c.poll()
+		}(p.done)
+	}
+
+	return w.ch, nil
+}
+
+// poll reads pins and dispatches events until there are no watchers left.
+func (c *IO) poll() {
+	p := &c.poller
+
+	for {
+		p.mu.Lock()
+
+		// Drop cancelled watchers.
+		ws := p.watchers[:0]
+		for _, w := range p.watchers {
+			if w.ctx.Err() != nil || p.stopped {
+				close(w.ch)
+				continue
+			}
+
+			ws = append(ws, w)
+		}
+		p.watchers = ws
+
+		if len(ws) == 0 {
+			p.running = false
+			p.mu.Unlock()
+			return
+		}
+
+		ws = append([]*pinWatcher(nil), ws...)
+
+		interval := p.interval
+		if interval <= 0 {
+			interval = defaultPollInterval
+		}
+
+		p.mu.Unlock()
+
+		all, err := c.ReadAll()
+		now := time.Now()
+
+		if err == nil {
+			for _, w := range ws {
+				ev, ok := w.next(all.Level(w.pin), now)
+				if !ok {
+					continue
+				}
+
+				select {
+				case w.ch <- ev:
+				case <-w.ctx.Done():
+				case <-p.stop:
+				}
+			}
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-p.stop:
+		}
+	}
+}
+
+// Edge selects level changes reported by WatchPin.
+type Edge uint8
+
+const (
+	EdgeBoth    Edge = iota // Every change.
+	EdgeRising              // Level becoming true.
+	EdgeFalling             // Level becoming false.
+)
+
+// PinLevel is a pin level transition reported by WatchPin.
+type PinLevel = PinEvent
+
+// WatchPin is like WatchPinContext, reporting only transitions of given edge.
+// Call returned stop func to stop watching, the channel is closed then. It's also closed
+// when Device is closed. ErrInvalidEdge is returned for unknown edge.
+//
+// Note: level has ReadPin meaning, so for input pin EdgeRising is the pin being shorted to GND.
+//
+// CH347 has no known GPIO interrupts in HID mode, so edges are detected by the shared poller
+// with the same latency as WatchPinContext: up to poll interval plus USB latency.
+//
+// Example:
+//
+//	// Count button presses.
+//	events, stop, err := c.WatchPin(ch347.GPIO3, ch347.EdgeRising)
+//	if err != nil {
+//		return err
+//	}
+//	defer stop()
+//
+//	for range events {
+//		presses++
+//	}
+func (c *IO) WatchPin(pin Pin, edge Edge) (<-chan PinLevel, func(), error) {
+	if edge > EdgeFalling {
+		return nil, nil, ErrInvalidEdge
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+
+	events, err := c.WatchPinContext(ctx, pin)
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+
+	ch := make(chan PinLevel, cap(events))
+
+	go func() {
+		defer close(ch)
+
+		for ev := range events {
+			if edge == EdgeBoth || ev.Level == (edge == EdgeRising) {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+				}
+			}
+		}
+	}()
+
+	return ch, stop, nil
+}
+
+// stopWatchers closes all watchers channels, waiting for the poller to exit,
+// and makes further WatchPin and WatchPinContext calls return closed channels.
+func (c *IO) stopWatchers() {
+	p := &c.poller
+	p.mu.Lock()
+
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+
+	p.stopped = true
+	if p.stop != nil {
+		close(p.stop)
+	}
+
+	running, done := p.running, p.done
+	p.mu.Unlock()
+
+	if running {
+		<-done
+	}
+}
+
+// next returns an event if level differs from the last known one.
+// The first reading only sets initial level.
+func (w *pinWatcher) next(level bool, now time.Time) (PinEvent, bool) {
+	if !w.seen {
+		w.seen = true
+		w.last = PinEvent{Pin: w.pin, Level: level, Time: now}
+		return PinEvent{}, false
+	}
+
+	if level == w.last.Level {
+		return PinEvent{}, false
+	}
+
+	ev := PinEvent{Pin: w.pin, Level: level, Time: now, Since: now.Sub(w.last.Time)}
+	w.last = ev
+
+	return ev, true
+}
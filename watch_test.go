@@ -0,0 +1,226 @@
+package ch347
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// setPin changes pin status byte returned by gpioResponder.
+func setPin(d *mockDev, pins *[8]byte, pin Pin, raw byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pins[pin] = raw
+}
+
+// nextEvent waits for an event, failing the test after a second.
+func nextEvent(t *testing.T, events <-chan PinEvent) PinEvent {
+	t.Helper()
+
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("events channel closed")
+		}
+
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+
+	return PinEvent{}
+}
+
+func TestWatchPin(t *testing.T) {
+	var pins [8]byte
+	pins[GPIO3] = 0x80 // Output low.
+
+	d := &mockDev{respond: gpioResponder(&pins)}
+	c := &IO{Dev: d}
+	c.SetPollInterval(time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := c.WatchPinContext(ctx, GPIO3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(20 * time.Millisecond) // Initial level is read.
+	setPin(d, &pins, GPIO3, 0xc0)
+
+	ev := nextEvent(t, events)
+	if ev.Pin != GPIO3 || !ev.Level || ev.Since < 15*time.Millisecond {
+		t.Fatalf("got %+v", ev)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	setPin(d, &pins, GPIO3, 0x80)
+
+	ev2 := nextEvent(t, events)
+	if ev2.Level || ev2.Since != ev2.Time.Sub(ev.Time) || ev2.Since < 5*time.Millisecond {
+		t.Fatalf("got %+v after %+v", ev2, ev)
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("event after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed on cancel")
+	}
+}
+
+func TestWatchPinInvalid(t *testing.T) {
+	c := &IO{Dev: &mockDev{}}
+
+	if _, err := c.WatchPinContext(context.Background(), 8); !errors.Is(err, ErrInvalidPin) {
+		t.Fatalf("got %v, want ErrInvalidPin", err)
+	}
+}
+
+func TestWatchPinShared(t *testing.T) {
+	var pins [8]byte
+	pins[GPIO3], pins[GPIO5] = 0x80, 0x80
+
+	d := &mockDev{respond: gpioResponder(&pins)}
+	c := &IO{Dev: d}
+	c.SetPollInterval(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ev3, err := c.WatchPinContext(ctx, GPIO3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev5, err := c.WatchPinContext(ctx, GPIO5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(55 * time.Millisecond)
+
+	// A poller per watcher would make about 12 requests.
+	if n := len(d.written()); n > 8 {
+		t.Fatalf("%d requests for 2 watchers in 5 intervals", n)
+	}
+
+	// Raw status change keeping the level isn't an event.
+	setPin(d, &pins, GPIO3, 0x81)
+	setPin(d, &pins, GPIO5, 0xc0)
+
+	if ev := nextEvent(t, ev5); ev.Pin != GPIO5 || !ev.Level {
+		t.Fatalf("got %+v", ev)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	select {
+	case ev := <-ev3:
+		t.Fatalf("unexpected event %+v", ev)
+	default:
+	}
+}
+
+func TestWatchPinShutdown(t *testing.T) {
+	var pins [8]byte
+	c := &IO{Dev: &mockDev{respond: gpioResponder(&pins)}}
+	c.SetPollInterval(time.Millisecond)
+
+	events, err := c.WatchPinContext(context.Background(), GPIO3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.stopWatchers()
+
+	if _, ok := <-events; ok {
+		t.Fatal("event after shutdown")
+	}
+
+	if c.poller.running {
+		t.Fatal("poller still running")
+	}
+
+	// No more watching once stopped.
+	events, err = c.WatchPinContext(context.Background(), GPIO3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := <-events; ok {
+		t.Fatal("event after shutdown")
+	}
+}
+
+func TestWatchPinEdge(t *testing.T) {
+	var pins [8]byte
+	pins[GPIO3] = 0x80 // Output low.
+
+	d := &mockDev{respond: gpioResponder(&pins)}
+	c := &IO{Dev: d}
+	c.SetPollInterval(time.Millisecond)
+
+	events, stop, err := c.WatchPin(GPIO3, EdgeRising)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	setPin(d, &pins, GPIO3, 0xc0)
+
+	if ev := nextEvent(t, events); ev.Pin != GPIO3 || !ev.Level {
+		t.Fatalf("got %+v", ev)
+	}
+
+	// Falling edge is skipped, next rising one is reported.
+	setPin(d, &pins, GPIO3, 0x80)
+	time.Sleep(10 * time.Millisecond)
+	setPin(d, &pins, GPIO3, 0xc0)
+
+	if ev := nextEvent(t, events); !ev.Level {
+		t.Fatalf("got falling edge %+v", ev)
+	}
+
+	stop()
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("event after stop")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed on stop")
+	}
+}
+
+func TestWatchPinEdgeInvalid(t *testing.T) {
+	d := &mockDev{}
+	c := &IO{Dev: d}
+
+	if _, _, err := c.WatchPin(8, EdgeBoth); !errors.Is(err, ErrInvalidPin) {
+		t.Fatalf("pin: got %v, want ErrInvalidPin", err)
+	}
+
+	if _, _, err := c.WatchPin(GPIO3, EdgeFalling+1); !errors.Is(err, ErrInvalidEdge) {
+		t.Fatalf("edge: got %v, want ErrInvalidEdge", err)
+	}
+
+	if err := c.AssignPin(GPIO2, PinRoleCS0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := c.WatchPin(GPIO2, EdgeBoth); err == nil {
+		t.Fatal("watching CS0 pin: no error")
+	}
+
+	if len(d.written()) != 0 || c.poller.running {
+		t.Fatal("poller started for invalid watch")
+	}
+}